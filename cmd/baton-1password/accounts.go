@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/connector"
+)
+
+// accountEntry is one account of the accounts file.
+// An account signs in either with a service account token or as a user, with all four user fields.
+type accountEntry struct {
+	Address             string `json:"address"`
+	Email               string `json:"email"`
+	SecretKey           string `json:"secret_key"`
+	Password            string `json:"password"`
+	ServiceAccountToken string `json:"service_account_token"`
+}
+
+// loadAccounts reads the accounts file, a JSON list of accountEntry.
+// rateReserve is the provisioning rate reserve of the service account entries.
+func loadAccounts(path string, rateReserve float64) ([]connector.AccountConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading accounts file: %w", err)
+	}
+
+	var entries []accountEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing accounts file: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("accounts file %s lists no accounts", path)
+	}
+
+	configs := make([]connector.AccountConfig, 0, len(entries))
+	for i, entry := range entries {
+		userFields := map[string]string{
+			"address":    entry.Address,
+			"email":      entry.Email,
+			"secret_key": entry.SecretKey,
+			"password":   entry.Password,
+		}
+
+		if entry.ServiceAccountToken != "" {
+			for name, val := range userFields {
+				if val != "" {
+					return nil, fmt.Errorf("account %d of the accounts file has both a service_account_token and '%s'", i+1, name)
+				}
+			}
+			configs = append(configs, connector.AccountConfig{
+				AuthType: authTypeService,
+				Token:    entry.ServiceAccountToken,
+				Options:  []onepassword.Option{onepassword.WithRateBudget(rateReserve)},
+			})
+			continue
+		}
+
+		for name, val := range userFields {
+			if val == "" {
+				return nil, fmt.Errorf("missing required field '%s' for account %d of the accounts file", name, i+1)
+			}
+		}
+		configs = append(configs, connector.AccountConfig{
+			AuthType: authTypeUser,
+			Details:  onepassword.NewAccount(entry.Address, entry.Email, entry.SecretKey, entry.Password),
+		})
+	}
+
+	return configs, nil
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 
 	onepassword "github.com/conductorone/baton-1password/pkg/client"
 	config2 "github.com/conductorone/baton-1password/pkg/config"
@@ -25,6 +28,7 @@ var (
 const (
 	authTypeService = "service"
 	authTypeUser    = "user"
+	authTypeSCIM    = onepassword.AuthTypeSCIM
 )
 
 func main() {
@@ -58,24 +62,92 @@ func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, e
 	}
 
 	authType := v.GetString(config2.AuthTypeField.FieldName)
+	accountsFile := v.GetString(config2.AccountsFileField.FieldName)
 
-	if err := validateConfigForAuthType(v, authType); err != nil {
+	if accountsFile == "" {
+		if err := validateConfigForAuthType(v, authType); err != nil {
+			return nil, err
+		}
+	}
+
+	deprovision := connector.DeprovisionPolicy(v.GetString(config2.AccountDeprovisioningField.FieldName))
+	if deprovision != connector.DeprovisionSuspend && deprovision != connector.DeprovisionDelete {
+		return nil, fmt.Errorf("account-deprovisioning must be '%s' or '%s', got '%s'", connector.DeprovisionSuspend, connector.DeprovisionDelete, deprovision)
+	}
+
+	digests := v.GetStringSlice(config2.OpSHA256Field.FieldName)
+	if err := validateDigests(digests); err != nil {
 		return nil, err
 	}
 
-	providedAccountDetails := onepassword.NewAccount(
-		v.GetString(config2.AddressField.FieldName),
-		v.GetString(config2.EmailField.FieldName),
-		v.GetString(config2.KeyField.FieldName),
-		v.GetString(config2.PasswordField.FieldName),
+	runner := onepassword.NewExecRunner(
+		onepassword.WithBinary(v.GetString(config2.OpPathField.FieldName)),
+		onepassword.WithBinaryDigests(digests...),
 	)
 
-	token, err := getAuthToken(ctx, authType, providedAccountDetails)
-	if err != nil {
-		return nil, err
+	var cliOpts []onepassword.Option
+	if authType == authTypeSCIM && accountsFile == "" {
+		scim, err := onepassword.NewSCIMClient(ctx,
+			v.GetString(config2.SCIMBridgeURLField.FieldName),
+			v.GetString(config2.SCIMBridgeTokenField.FieldName),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error creating SCIM bridge client: %w", err)
+		}
+		cliOpts = append(cliOpts, onepassword.WithSCIMBridge(scim))
+	}
+
+	reserve := v.GetInt(config2.ProvisioningRateReserveField.FieldName)
+	if reserve < 0 || reserve > 100 {
+		return nil, fmt.Errorf("provisioning-rate-reserve must be between 0 and 100, got %d", reserve)
+	}
+	if authType == authTypeService && accountsFile == "" {
+		cliOpts = append(cliOpts, onepassword.WithRateBudget(float64(reserve)/100))
+	}
+
+	concurrency := v.GetInt(config2.OpConcurrencyField.FieldName)
+	if concurrency < 1 {
+		return nil, fmt.Errorf("op-concurrency must be at least 1, got %d", concurrency)
+	}
+	cliOpts = append(cliOpts, onepassword.WithConcurrency(concurrency))
+
+	// The events of an Events Reporting integration belong to a single account, config forbids them with an accounts file.
+	if eventsToken := v.GetString(config2.EventsAPITokenField.FieldName); eventsToken != "" && accountsFile == "" {
+		events, err := onepassword.NewEventsAPIClient(ctx, v.GetString(config2.EventsAPIURLField.FieldName), eventsToken)
+		if err != nil {
+			return nil, fmt.Errorf("error creating events api client: %w", err)
+		}
+		cliOpts = append(cliOpts, onepassword.WithEventsAPI(events))
+	}
+
+	var (
+		accounts []connector.AccountConfig
+		err      error
+	)
+	if accountsFile != "" {
+		accounts, err = loadAccounts(accountsFile, float64(reserve)/100)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		token, err := getAuthToken(authType)
+		if err != nil {
+			return nil, err
+		}
+		accounts = []connector.AccountConfig{{
+			AuthType: authType,
+			Token:    token,
+			Details: onepassword.NewAccount(
+				v.GetString(config2.AddressField.FieldName),
+				v.GetString(config2.EmailField.FieldName),
+				v.GetString(config2.KeyField.FieldName),
+				v.GetString(config2.PasswordField.FieldName),
+			),
+		}}
 	}
 
-	cb, err := connector.New(ctx, authType, token, providedAccountDetails, v.GetStringSlice(config2.LimitVaultPermissionsField.FieldName))
+	cb, err := connector.NewWithAccounts(ctx, accounts, runner, v.GetStringSlice(config2.LimitVaultPermissionsField.FieldName), deprovision,
+		v.GetBool(config2.ForceVaultDeletionField.FieldName), cliOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("error creating connector: %w", err)
 	}
@@ -102,17 +174,23 @@ func validateVaultPermissions(perms []string, l *zap.Logger) error {
 	return nil
 }
 
-func getAuthToken(ctx context.Context, authType string, acc *onepassword.AccountDetails) (string, error) {
+func validateDigests(digests []string) error {
+	for _, digest := range digests {
+		if b, err := hex.DecodeString(strings.TrimSpace(digest)); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid op-sha256 digest %q: expected 64 hexadecimal characters", digest)
+		}
+	}
+	return nil
+}
+
+func getAuthToken(authType string) (string, error) {
 	switch authType {
-	case authTypeService:
+	case authTypeService, authTypeSCIM:
 		return os.Getenv("OP_SERVICE_ACCOUNT_TOKEN"), nil
 
 	case authTypeUser:
-		token, err := onepassword.GetUserToken(ctx, acc)
-		if err != nil {
-			return "", fmt.Errorf("unable to get user token: %w", err)
-		}
-		return token, nil
+		// The connector signs in itself, in its own op configuration directory.
+		return "", nil
 
 	default:
 		return "", fmt.Errorf("authType provided ('%s') is not supported", authType)
@@ -143,6 +221,19 @@ func validateConfigForAuthType(v *viper.Viper, authType string) error {
 			return err
 		}
 
+	case authTypeSCIM:
+		requiredFields := map[string]string{
+			"scim-bridge-url":   config2.SCIMBridgeURLField.FieldName,
+			"scim-bridge-token": config2.SCIMBridgeTokenField.FieldName,
+		}
+		for name, field := range requiredFields {
+			val := v.GetString(field)
+			if val == "" {
+				err := fmt.Errorf("missing required field '%s' for auth-type 'scim'", name)
+				return err
+			}
+		}
+
 	default:
 		err := fmt.Errorf("unsupported auth-type: %s", authType)
 		return err
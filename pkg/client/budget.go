@@ -0,0 +1,308 @@
+package onepassword
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	// The budget is refreshed from op after this many commands or this much time, whichever comes first.
+	budgetRefreshCommands = 100
+	budgetRefreshInterval = 5 * time.Minute
+
+	// Below this share of a limit, commands are paced evenly over the rest of the window.
+	budgetPacingShare = 0.1
+
+	// Quota windows of service accounts last an hour, used when op does not report a reset time.
+	defaultQuotaWindow = time.Hour
+
+	defaultBudgetPhase = "other"
+
+	// provisioningBudgetPhase labels the commands of grants, revokes and other provisioning changes, reads included.
+	provisioningBudgetPhase = "provisioning"
+)
+
+type budgetPhaseKey struct{}
+
+// WithBudgetPhase labels the op commands run with ctx, so rate limit usage can be reported per sync phase.
+func WithBudgetPhase(ctx context.Context, phase string) context.Context {
+	return context.WithValue(ctx, budgetPhaseKey{}, phase)
+}
+
+func budgetPhase(ctx context.Context) string {
+	if phase, ok := ctx.Value(budgetPhaseKey{}).(string); ok {
+		return phase
+	}
+	return defaultBudgetPhase
+}
+
+// WithRateBudget tracks the service account request quotas and throttles commands to stay within them.
+// reserve is the share of each quota, between 0 and 1, that sync reads leave for provisioning.
+// Commands run WithBudgetPhase "provisioning" may use it, reads and writes alike, and so may any write.
+func WithRateBudget(reserve float64) Option {
+	return func(c *OnePasswordClient) {
+		c.budget = &rateBudget{
+			reserve: min(max(reserve, 0), 1),
+			usage:   make(map[string]int),
+			now:     time.Now,
+		}
+	}
+}
+
+type budgetBucket struct {
+	RateLimit
+	resetAt time.Time
+}
+
+// appliesTo reports whether a command counts against the bucket.
+func (b *budgetBucket) appliesTo(write bool) bool {
+	switch b.Action {
+	case "read":
+		return !write
+	case "write":
+		return write
+	default:
+		return true
+	}
+}
+
+// rateBudget mirrors the service account quotas locally, between refreshes from `op service-account ratelimit`.
+type rateBudget struct {
+	mu           sync.Mutex
+	reserve      float64
+	buckets      []*budgetBucket
+	refreshedAt  time.Time
+	sinceRefresh int
+	// nextSlot is when the last paced command may run, so concurrent commands are spaced out too.
+	nextSlot time.Time
+	// phase -> number of commands run.
+	usage map[string]int
+	now   func() time.Time
+}
+
+func (b *rateBudget) stale() bool {
+	if b.refreshedAt.IsZero() || b.sinceRefresh >= budgetRefreshCommands || b.now().Sub(b.refreshedAt) >= budgetRefreshInterval {
+		return true
+	}
+	for _, bucket := range b.buckets {
+		if !bucket.resetAt.IsZero() && !b.now().Before(bucket.resetAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *rateBudget) update(limits []RateLimit) {
+	now := b.now()
+
+	b.buckets = b.buckets[:0]
+	for _, limit := range limits {
+		bucket := &budgetBucket{RateLimit: limit}
+		if limit.Reset > 0 {
+			bucket.resetAt = now.Add(time.Duration(limit.Reset) * time.Second)
+		}
+		b.buckets = append(b.buckets, bucket)
+	}
+	b.refreshedAt = now
+	b.sinceRefresh = 0
+}
+
+// keepEstimate postpones the next refresh after a failed one, forgetting the quotas whose window has ended.
+func (b *rateBudget) keepEstimate() {
+	now := b.now()
+
+	b.buckets = slices.DeleteFunc(b.buckets, func(bucket *budgetBucket) bool {
+		return !bucket.resetAt.IsZero() && !now.Before(bucket.resetAt)
+	})
+	b.refreshedAt = now
+	b.sinceRefresh = 0
+}
+
+// take takes one request from every bucket the command counts against.
+// Only writes and provisioning commands may dip into the reserve. It returns how long to wait before running the command, or a RateLimitError if the budget is spent.
+func (b *rateBudget) take(write bool, phase string) (time.Duration, error) {
+	now := b.now()
+
+	var wait time.Duration
+	for _, bucket := range b.buckets {
+		if !bucket.appliesTo(write) {
+			continue
+		}
+
+		resetAt := bucket.resetAt
+		if resetAt.IsZero() {
+			resetAt = now.Add(defaultQuotaWindow)
+		}
+
+		available := bucket.Remaining
+		if !write && phase != provisioningBudgetPhase {
+			available -= int(math.Ceil(b.reserve * float64(bucket.Limit)))
+		}
+		if available <= 0 {
+			return 0, &RateLimitError{
+				ResetAt: resetAt,
+				Err:     fmt.Errorf("%s %s budget of the service account is spent: %w", bucket.Type, bucket.Action, ErrRateLimited),
+			}
+		}
+
+		if float64(available) < budgetPacingShare*float64(bucket.Limit) {
+			wait = max(wait, resetAt.Sub(now)/time.Duration(available))
+		}
+	}
+
+	for _, bucket := range b.buckets {
+		if bucket.appliesTo(write) {
+			bucket.Remaining--
+			bucket.Used++
+		}
+	}
+	b.sinceRefresh++
+	b.usage[phase]++
+
+	if wait > 0 {
+		b.nextSlot = maxTime(b.nextSlot, now).Add(wait)
+		wait = b.nextSlot.Sub(now)
+	}
+
+	return wait, nil
+}
+
+// resetAt returns when the quotas a command counts against free up again, for a command op reported as rate limited.
+// That is the latest reset of the spent quotas or, when none is spent locally, the earliest reset of the others.
+// ok is false when no quota the command counts against has a known reset time.
+func (b *rateBudget) resetAt(write bool) (time.Time, bool) {
+	var spent, next time.Time
+	for _, bucket := range b.buckets {
+		if !bucket.appliesTo(write) || bucket.resetAt.IsZero() {
+			continue
+		}
+		if bucket.Remaining <= 0 {
+			spent = maxTime(spent, bucket.resetAt)
+		}
+		if next.IsZero() || bucket.resetAt.Before(next) {
+			next = bucket.resetAt
+		}
+	}
+
+	if !spent.IsZero() {
+		return spent, true
+	}
+	return next, !next.IsZero()
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// RefreshRateBudget reads the current service account quotas and logs the budget used so far by each sync phase.
+// It does nothing unless the client was created WithRateBudget and op supports CapabilityRateLimits.
+func (c *OnePasswordClient) RefreshRateBudget(ctx context.Context) error {
+	if c.budget == nil || !c.Supports(CapabilityRateLimits) {
+		return nil
+	}
+
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+
+	return c.refreshRateBudget(ctx)
+}
+
+// refreshRateBudget must be called with the budget lock held.
+func (c *OnePasswordClient) refreshRateBudget(ctx context.Context) error {
+	// The quota query goes straight to op, it must not wait on the budget it refreshes.
+	out, err := c.executeOnce(ctx, []string{"service-account", "ratelimit"})
+	if err != nil {
+		return fmt.Errorf("error getting service account rate limits: %w", err)
+	}
+	if out.ExitCode != 0 {
+		return fmt.Errorf("error getting service account rate limits: %w", newCommandError(out))
+	}
+
+	var limits []RateLimit
+	if err := json.Unmarshal(out.Stdout, &limits); err != nil {
+		return fmt.Errorf("error unmarshalling response: %w", err)
+	}
+
+	c.budget.update(limits)
+
+	fields := make([]zap.Field, 0, len(limits)+1)
+	for _, limit := range limits {
+		fields = append(fields, zap.String(limit.Type+"_"+limit.Action, fmt.Sprintf("%d/%d remaining", limit.Remaining, limit.Limit)))
+	}
+	fields = append(fields, zap.Any("usage_by_phase", maps.Clone(c.budget.usage)))
+	ctxzap.Extract(ctx).Info("service account rate limit budget", fields...)
+
+	return nil
+}
+
+// RateBudgetUsage returns the number of op commands run in each sync phase.
+func (c *OnePasswordClient) RateBudgetUsage() map[string]int {
+	if c.budget == nil || !c.Supports(CapabilityRateLimits) {
+		return nil
+	}
+
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+
+	return maps.Clone(c.budget.usage)
+}
+
+// rateLimitResetAt returns the earliest time a command that op reported as rate limited should be tried again.
+// It is the reset time of the service account quotas when the rate budget knows it, MaxDelay of the RetryPolicy from now otherwise.
+func (c *OnePasswordClient) rateLimitResetAt(args []string) time.Time {
+	if c.budget != nil && c.Supports(CapabilityRateLimits) {
+		c.budget.mu.Lock()
+		resetAt, ok := c.budget.resetAt(!isReadCommand(args))
+		c.budget.mu.Unlock()
+		if ok && resetAt.After(time.Now()) {
+			return resetAt
+		}
+	}
+	return time.Now().Add(c.retry.MaxDelay)
+}
+
+// waitForBudget blocks until the rate budget allows the command to run.
+// The share of the budget that is reserved for provisioning is left to writes and to the commands of the provisioning phase,
+// since grants and revokes read the current state before they write.
+func (c *OnePasswordClient) waitForBudget(ctx context.Context, args []string) error {
+	if c.budget == nil || !c.Supports(CapabilityRateLimits) {
+		return nil
+	}
+
+	c.budget.mu.Lock()
+	if c.budget.stale() {
+		if err := c.refreshRateBudget(ctx); err != nil {
+			// Keep going on the local estimate, the command itself fails if the quota is really spent.
+			ctxzap.Extract(ctx).Warn("unable to refresh service account rate limit budget", zap.Error(err))
+			c.budget.keepEstimate()
+		}
+	}
+	wait, err := c.budget.take(!isReadCommand(args), budgetPhase(ctx))
+	c.budget.mu.Unlock()
+
+	if err != nil || wait <= 0 {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
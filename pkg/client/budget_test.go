@@ -0,0 +1,109 @@
+package onepassword_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/stretchr/testify/require"
+)
+
+func newBudgetEmulator(limits ...onepassword.RateLimit) *optest.Emulator {
+	return newTestEmulator().SetRateLimits(limits...)
+}
+
+func countCommands(calls []optest.Call, command ...string) int {
+	var n int
+	for _, c := range calls {
+		if len(c.Args) >= len(command) && slices.Equal(c.Args[:len(command)], command) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRateBudgetReservesProvisioning(t *testing.T) {
+	emu := newBudgetEmulator(onepassword.RateLimit{Type: "account", Action: "read_write", Limit: 10, Remaining: 10, Reset: 3600})
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithRateBudget(0.5))
+
+	ctx := onepassword.WithBudgetPhase(t.Context(), "groups")
+	for range 5 {
+		_, err := cli.ListGroups(ctx)
+		require.NoError(t, err)
+	}
+
+	_, err := cli.ListGroups(ctx)
+	require.ErrorIs(t, err, onepassword.ErrRateLimited)
+	var rlErr *onepassword.RateLimitError
+	require.ErrorAs(t, err, &rlErr)
+	require.WithinDuration(t, time.Now().Add(time.Hour), rlErr.ResetAt, time.Minute)
+
+	// Provisioning reads the current state before it writes, both may use the reserve.
+	ctx = onepassword.WithBudgetPhase(t.Context(), "provisioning")
+	_, err = cli.ListGroups(ctx)
+	require.NoError(t, err)
+	require.NoError(t, cli.AddUserToGroup(ctx, "G1", "member", "U1"))
+
+	calls := emu.Calls()
+	require.Equal(t, 6, countCommands(calls, "group", "list"))
+	require.Equal(t, 1, countCommands(calls, "service-account", "ratelimit"))
+	require.Equal(t, map[string]int{"groups": 5, "provisioning": 2}, cli.RateBudgetUsage())
+}
+
+func TestRateBudgetSeparateReadAndWriteQuotas(t *testing.T) {
+	emu := newBudgetEmulator(
+		onepassword.RateLimit{Type: "token", Action: "read", Limit: 2, Remaining: 2, Reset: 3600},
+		onepassword.RateLimit{Type: "token", Action: "write", Limit: 100, Remaining: 100},
+	)
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithRateBudget(0))
+
+	for range 2 {
+		_, err := cli.ListUsers(t.Context())
+		require.NoError(t, err)
+	}
+	_, err := cli.ListUsers(t.Context())
+	require.ErrorIs(t, err, onepassword.ErrRateLimited)
+
+	require.NoError(t, cli.AddUserToGroup(t.Context(), "G1", "member", "U1"))
+	require.Equal(t, map[string]int{"other": 3}, cli.RateBudgetUsage())
+}
+
+func TestRateBudgetPacesWhenLow(t *testing.T) {
+	emu := newBudgetEmulator(onepassword.RateLimit{Type: "account", Action: "read_write", Limit: 100, Remaining: 5, Reset: 3600})
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithRateBudget(0))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	// With 5 requests left for the next hour, the client spaces them out instead of spending them at once.
+	_, err := cli.ListUsers(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Zero(t, countCommands(emu.Calls(), "user", "list"))
+}
+
+func TestRateLimitReportedByOpResetsWithBudget(t *testing.T) {
+	runner := optest.NewScriptedRunner().
+		OnJSON([]string{"service-account", "ratelimit"}, `[{"type":"token","action":"write","limit":100,"used":40,"remaining":60,"reset":1800}]`).
+		On([]string{"group", "user", "grant"}, rateLimited)
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithRateBudget(0))
+
+	err := cli.AddUserToGroup(t.Context(), "G1", "member", "U1")
+	require.ErrorIs(t, err, onepassword.ErrRateLimited)
+	var rlErr *onepassword.RateLimitError
+	require.ErrorAs(t, err, &rlErr)
+	require.WithinDuration(t, time.Now().Add(30*time.Minute), rlErr.ResetAt, time.Minute)
+}
+
+func TestRateBudgetDisabled(t *testing.T) {
+	emu := newBudgetEmulator()
+	cli := onepassword.NewCli("service", "", emu)
+
+	require.NoError(t, cli.RefreshRateBudget(t.Context()))
+	_, err := cli.ListUsers(t.Context())
+	require.NoError(t, err)
+	require.Zero(t, countCommands(emu.Calls(), "service-account", "ratelimit"))
+	require.Nil(t, cli.RateBudgetUsage())
+}
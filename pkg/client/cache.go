@@ -0,0 +1,125 @@
+package onepassword
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCacheTTL bounds how stale a cached op read can be. Sync phases read the same vaults and groups within minutes.
+const DefaultCacheTTL = 5 * time.Minute
+
+// WithCache caches the output of op reads for ttl.
+// Concurrent identical reads share a single op invocation, and every write clears the cache.
+func WithCache(ttl time.Duration) Option {
+	return func(c *OnePasswordClient) {
+		c.cache = &responseCache{
+			ttl:     ttl,
+			entries: make(map[string]cacheEntry),
+			now:     time.Now,
+		}
+	}
+}
+
+type freshReadsKey struct{}
+
+// WithFreshReads makes the op reads run with ctx skip cached output, for decisions that must see the current state,
+// such as whether a grant is already held. What they read is cached for later reads.
+func WithFreshReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshReadsKey{}, true)
+}
+
+func freshReads(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshReadsKey{}).(bool)
+	return fresh
+}
+
+type cacheEntry struct {
+	stdout  []byte
+	expires time.Time
+}
+
+// responseCache holds the raw stdout of op reads.
+// Callers decode their own copy, so cached results cannot be modified through a returned slice.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	// generation is bumped by invalidate, so reads started before a write are not cached after it.
+	generation uint64
+	flights    singleflight.Group
+	now        func() time.Time
+}
+
+func cacheKey(args []string) string {
+	return strings.Join(args, "\x00")
+}
+
+// get returns the cached output for args, or runs fetch once for all concurrent callers and caches its result.
+// A caller whose ctx ends stops waiting, while the shared fetch keeps running for the others.
+// With WithFreshReads, cached output is skipped, and so are reads started before, which fresh reads only share among themselves.
+func (rc *responseCache) get(ctx context.Context, args []string, fetch func() ([]byte, error)) ([]byte, error) {
+	key := cacheKey(args)
+	fresh := freshReads(ctx)
+
+	for {
+		rc.mu.Lock()
+		if entry, ok := rc.entries[key]; ok && !fresh && rc.now().Before(entry.expires) {
+			rc.mu.Unlock()
+			return entry.stdout, nil
+		}
+		generation := rc.generation
+		rc.mu.Unlock()
+
+		flightKey := strconv.FormatUint(generation, 10) + "\x00" + key
+		if fresh {
+			flightKey = "fresh\x00" + flightKey
+		}
+		flight := rc.flights.DoChan(flightKey, func() (any, error) {
+			stdout, err := fetch()
+			if err != nil {
+				return nil, err
+			}
+
+			rc.mu.Lock()
+			if rc.generation == generation {
+				rc.entries[key] = cacheEntry{stdout: stdout, expires: rc.now().Add(rc.ttl)}
+			}
+			rc.mu.Unlock()
+
+			return stdout, nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-flight:
+			switch {
+			case res.Err == nil:
+				return res.Val.([]byte), nil
+			case isContextError(res.Err) && res.Shared && ctx.Err() == nil:
+				// The fetch ran on the context of another caller, which ended. Run it again on this one.
+				continue
+			default:
+				return nil, res.Err
+			}
+		}
+	}
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (rc *responseCache) invalidate() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.generation++
+	clear(rc.entries)
+}
@@ -0,0 +1,141 @@
+package onepassword_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedRunner holds every command until release is closed.
+type gatedRunner struct {
+	onepassword.CommandRunner
+	release chan struct{}
+}
+
+func (r *gatedRunner) Run(ctx context.Context, args []string, stdin []byte, env []string) (*onepassword.CommandResult, error) {
+	<-r.release
+	return r.CommandRunner.Run(ctx, args, stdin, env)
+}
+
+func newCacheEmulator() *optest.Emulator {
+	return newTestEmulator().AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}})
+}
+
+func TestCacheSharesConcurrentReads(t *testing.T) {
+	emu := newCacheEmulator()
+	runner := &gatedRunner{CommandRunner: emu, release: make(chan struct{})}
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithCache(time.Minute))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			groups, err := cli.ListGroups(t.Context())
+			require.NoError(t, err)
+			require.Len(t, groups, 1)
+		})
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(runner.release)
+	wg.Wait()
+
+	_, err := cli.ListGroups(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 1, countCommands(emu.Calls(), "group", "list"))
+}
+
+func TestCacheExpires(t *testing.T) {
+	emu := newCacheEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(time.Millisecond))
+
+	_, err := cli.GetAccount(t.Context())
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = cli.GetAccount(t.Context())
+	require.NoError(t, err)
+
+	require.Equal(t, 2, countCommands(emu.Calls(), "account", "get"))
+}
+
+func TestCacheInvalidatedByProvisioning(t *testing.T) {
+	emu := newCacheEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(time.Minute))
+	ctx := t.Context()
+
+	members, err := cli.ListGroupMembers(ctx, "G1")
+	require.NoError(t, err)
+	require.Empty(t, members)
+	vaultUsers, err := cli.ListVaultMembers(ctx, "V1")
+	require.NoError(t, err)
+	require.Empty(t, vaultUsers)
+
+	require.NoError(t, cli.AddUserToGroup(ctx, "G1", "member", "U1"))
+	members, err = cli.ListGroupMembers(ctx, "G1")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+
+	require.NoError(t, cli.AddUserToVault(ctx, "V1", "U1", "view_items"))
+	vaultUsers, err = cli.ListVaultMembers(ctx, "V1")
+	require.NoError(t, err)
+	require.Len(t, vaultUsers, 1)
+
+	calls := emu.Calls()
+	require.Equal(t, 2, countCommands(calls, "group", "user", "list"))
+	require.Equal(t, 2, countCommands(calls, "vault", "user", "list"))
+}
+
+func TestFreshReadsSkipTheCache(t *testing.T) {
+	emu := newCacheEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(time.Minute))
+	ctx := t.Context()
+
+	members, err := cli.ListGroupMembers(ctx, "G1")
+	require.NoError(t, err)
+	require.Empty(t, members)
+
+	// Changes made outside of the client are not seen through the cache.
+	emu.SetGroupMember("G1", "U1", "MEMBER")
+	members, err = cli.ListGroupMembers(ctx, "G1")
+	require.NoError(t, err)
+	require.Empty(t, members)
+
+	members, err = cli.ListGroupMembers(onepassword.WithFreshReads(ctx), "G1")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+
+	// The fresh read updated the cache.
+	members, err = cli.ListGroupMembers(ctx, "G1")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, 2, countCommands(emu.Calls(), "group", "user", "list"))
+}
+
+func TestCacheDoesNotServeWritesNamedLikeReads(t *testing.T) {
+	emu := newCacheEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(time.Minute))
+	ctx := t.Context()
+
+	_, err := cli.ListGroups(ctx)
+	require.NoError(t, err)
+
+	first, err := cli.CreateGroup(ctx, "list", "")
+	require.NoError(t, err)
+	second, err := cli.CreateGroup(ctx, "list", "")
+	require.NoError(t, err)
+	require.NotEqual(t, first.ID, second.ID)
+
+	_, err = cli.CreateVault(ctx, "get", "", "", true)
+	require.NoError(t, err)
+
+	groups, err := cli.ListGroups(ctx)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+
+	calls := emu.Calls()
+	require.Equal(t, 2, countCommands(calls, "group", "create"))
+	require.Equal(t, 1, countCommands(calls, "vault", "create"))
+}
@@ -1,29 +1,124 @@
 package onepassword
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"os"
-	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
 )
 
+const AuthTypeSCIM = "scim"
+
 type OnePasswordClient struct {
 	authType string
-	token    string
+	runner   CommandRunner
+	scim     *SCIMClient
+	events   *EventsAPIClient
+	retry    RetryPolicy
+	budget   *rateBudget
+	cache    *responseCache
+	redactor redactor
+	// prefetcher is nil unless prefetching is enabled WithConcurrency.
+	prefetcher *prefetcher
+	// version is the op release found by DetectVersion, nil until it runs.
+	version *Version
+
+	// configDir is the private op configuration directory of the client, see WithConfigDir.
+	configDir string
+	// account scopes every command to one of the accounts op is signed in to, see WithAccount.
+	account string
+
+	// sessionMu guards token and sessionUser, which are replaced when a user-mode session expires.
+	sessionMu      sync.RWMutex
+	token          string
+	sessionUser    string
+	accountDetails *AccountDetails
+}
+
+type Option func(c *OnePasswordClient)
+
+// WithSCIMBridge routes user and group operations to a SCIM bridge instead of the op CLI.
+func WithSCIMBridge(scim *SCIMClient) Option {
+	return func(c *OnePasswordClient) {
+		c.scim = scim
+	}
+}
+
+// WithEventsAPI enables the event feeds backed by the 1Password Events API.
+func WithEventsAPI(events *EventsAPIClient) Option {
+	return func(c *OnePasswordClient) {
+		c.events = events
+	}
+}
+
+// WithAccount passes --account to every command, so it runs against that account even when op knows several.
+// account is a sign-in address, an account ID or a user ID.
+func WithAccount(account string) Option {
+	return func(c *OnePasswordClient) {
+		c.account = account
+	}
+}
+
+// WithAccountDetails lets a user-mode client sign in again when its session expires.
+func WithAccountDetails(details *AccountDetails) Option {
+	return func(c *OnePasswordClient) {
+		c.accountDetails = details
+	}
 }
 
-func NewCli(authType string, token string) *OnePasswordClient {
-	return &OnePasswordClient{
+func NewCli(authType string, token string, runner CommandRunner, opts ...Option) *OnePasswordClient {
+	c := &OnePasswordClient{
 		authType: authType,
 		token:    token,
+		runner:   runner,
+		retry:    DefaultRetryPolicy,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.redactor.add(token)
+	if c.accountDetails != nil {
+		c.redactor.add(c.accountDetails.secret, c.accountDetails.password)
+	}
+
+	return c
+}
+
+// Close stops prefetching, waiting for its workers, and removes the op configuration directory of the client.
+// The op commands started by the workers are killed through their context.
+func (c *OnePasswordClient) Close() error {
+	if c.prefetcher != nil {
+		c.prefetcher.cancel()
+		c.prefetcher.wg.Wait()
+	}
+
+	return c.removeConfigDir()
+}
+
+// HasCLI reports whether account and vault operations can be run through the op CLI.
+// With the SCIM backend the CLI is only used when a service account token is available.
+func (c *OnePasswordClient) HasCLI() bool {
+	return c.authType != AuthTypeSCIM || c.sessionToken() != ""
+}
+
+// Events returns the Events API client, or nil if none is configured.
+func (c *OnePasswordClient) Events() *EventsAPIClient {
+	return c.events
+}
+
+// ValidateSCIMBridge checks the SCIM bridge connection, if one is configured.
+func (c *OnePasswordClient) ValidateSCIMBridge(ctx context.Context) error {
+	if c.scim == nil {
+		return nil
+	}
+	return c.scim.Validate(ctx)
 }
 
 func NewAccount(address string, email string, secret string, password string) *AccountDetails {
@@ -35,192 +130,131 @@ func NewAccount(address string, email string, secret string, password string) *A
 	}
 }
 
-// Get the accounts listed on the local config.
-func GetLocalAccounts(ctx context.Context) ([]LocalAccountDetails, error) {
+// Address returns the sign-in address of the account.
+func (a *AccountDetails) Address() string {
+	return a.address
+}
+
+// GetLocalAccounts gets the accounts added to the op configuration of the client.
+func (c *OnePasswordClient) GetLocalAccounts(ctx context.Context) ([]LocalAccountDetails, error) {
 	l := ctxzap.Extract(ctx)
 
-	var err error
-	var output []byte
-
-	cmd := exec.CommandContext(ctx, "op", "accounts", "list", "--format=json")
-	if output, err = cmd.Output(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			l.Error(
-				"error executing 'op accounts list' command",
-				zap.Error(err),
-				zap.String("stderr", string(exitErr.Stderr)),
-				zap.Int("exit_code", exitErr.ExitCode()),
-			)
-		}
+	res, err := c.run(ctx, []string{"accounts", "list", "--format=json"}, nil, c.configEnv())
+	if err != nil {
 		return nil, fmt.Errorf("error executing command: %w", err)
 	}
 
+	if res.ExitCode != 0 {
+		l.Error(
+			"error executing 'op accounts list' command",
+			zap.String("stderr", string(res.Stderr)),
+			zap.Int("exit_code", res.ExitCode),
+		)
+		return nil, fmt.Errorf("error executing command: %w", newCommandError(res))
+	}
+
 	var accounts []LocalAccountDetails
-	if err = json.Unmarshal(output, &accounts); err != nil {
+	if err = json.Unmarshal(res.Stdout, &accounts); err != nil {
 		return nil, fmt.Errorf("error unmarshalling response: %w", err)
 	}
 
 	return accounts, nil
 }
 
-// Returns the account UUID.
-func GetLocalAccountUUID(ctx context.Context, email string) (string, error) {
-	accounts, err := GetLocalAccounts(ctx)
+// normalizeAddress returns the host of a sign-in address, which op lists with or without a scheme.
+func normalizeAddress(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	address = strings.TrimPrefix(address, "https://")
+	return strings.TrimSuffix(address, "/")
+}
+
+// findLocalAccount returns the local account of the user with the given email at the sign-in address,
+// or nil if it has not been added. The same email can have accounts at several addresses.
+func (c *OnePasswordClient) findLocalAccount(ctx context.Context, address, email string) (*LocalAccountDetails, error) {
+	accounts, err := c.GetLocalAccounts(ctx)
 	if err != nil {
-		return "", fmt.Errorf("error getting local accounts: %w", err)
+		return nil, fmt.Errorf("error getting local accounts: %w", err)
 	}
 
 	for _, account := range accounts {
-		if account.Email == email {
-			return account.AccountUUID, nil
+		if normalizeAddress(account.URL) == normalizeAddress(address) && strings.EqualFold(account.Email, email) {
+			return &account, nil
 		}
 	}
 
-	return "", nil
+	return nil, nil
 }
 
-// Adds a user account to the local config.
-func AddLocalAccount(ctx context.Context, providedAccountDetails *AccountDetails) (string, error) {
-	l := ctxzap.Extract(ctx)
-
-	var (
-		err     error
-		addIn   io.WriteCloser
-		account string
-	)
-
-	// Must be provided as environment variable
-	if err := os.Setenv("OP_SECRET_KEY", providedAccountDetails.secret); err != nil {
-		return "", err
-	}
-
-	args := []string{"account", "add", "--address", providedAccountDetails.address, "--email", providedAccountDetails.email, "--raw"}
-
-	addCmd := exec.CommandContext(ctx, "op", args...)
-	if addIn, err = addCmd.StdinPipe(); err != nil {
-		return "", err
-	}
-
-	if err = addCmd.Start(); err != nil {
-		return "", err
-	}
-
-	if _, err = fmt.Fprintf(addIn, "%s\n", providedAccountDetails.password); err != nil {
-		return "", err
-	}
-
-	if err = addIn.Close(); err != nil {
+// Returns the account UUID.
+func (c *OnePasswordClient) GetLocalAccountUUID(ctx context.Context, address, email string) (string, error) {
+	account, err := c.findLocalAccount(ctx, address, email)
+	if err != nil || account == nil {
 		return "", err
 	}
 
-	if err = addCmd.Wait(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			l.Error(
-				"error executing 'op account add' command",
-				zap.Error(err),
-				zap.String("stderr", string(exitErr.Stderr)),
-				zap.Int("exit_code", exitErr.ExitCode()),
-			)
-		}
-		return "", fmt.Errorf("error starting command: %w", err)
-	}
-
-	if account, err = GetLocalAccountUUID(ctx, providedAccountDetails.email); err != nil {
-		return "", fmt.Errorf("error getting accountuuid after account add: %w", err)
-	}
-
-	l.Debug(fmt.Sprintf("Local account added: %s", account))
-
-	return account, nil
+	return account.AccountUUID, nil
 }
 
-func GetUserToken(ctx context.Context, providedAccountDetails *AccountDetails) (string, error) {
+// Adds a user account to the op configuration of the client.
+func (c *OnePasswordClient) addLocalAccount(ctx context.Context, providedAccountDetails *AccountDetails) (*LocalAccountDetails, error) {
 	l := ctxzap.Extract(ctx)
 
-	var (
-		err error
-	)
-
-	if providedAccountDetails.password == "" {
-		l.Error("password must be provided")
-		return "", fmt.Errorf("password is required for user auth-type")
-	}
+	args := []string{"account", "add", "--address", providedAccountDetails.address, "--email", providedAccountDetails.email, "--raw"}
+	stdin := []byte(providedAccountDetails.password + "\n")
+	// The secret key is only accepted through the environment, and only this command needs it.
+	env := append(c.configEnv(), "OP_SECRET_KEY="+providedAccountDetails.secret)
 
-	account, err := GetLocalAccountUUID(ctx, providedAccountDetails.email)
+	res, err := c.run(ctx, args, stdin, env)
 	if err != nil {
-		l.Error("failed to check local accounts: ", zap.Error(err))
-		return "", err
+		return nil, fmt.Errorf("error starting command: %w", err)
 	}
 
-	if account == "" {
-		if account, err = AddLocalAccount(ctx,
-			providedAccountDetails,
-		); err != nil {
-			l.Error("failed to add local account: ", zap.Error(err))
-			return "", err
-		}
+	if res.ExitCode != 0 {
+		l.Error(
+			"error executing 'op account add' command",
+			zap.String("stderr", string(res.Stderr)),
+			zap.Int("exit_code", res.ExitCode),
+		)
+		return nil, fmt.Errorf("error starting command: %w", newCommandError(res))
 	}
 
-	token, err := SignIn(ctx,
-		account,
-		providedAccountDetails,
-	)
-
+	account, err := c.findLocalAccount(ctx, providedAccountDetails.address, providedAccountDetails.email)
 	if err != nil {
-		l.Error("failed to SignIn: ", zap.Error(err))
-		return "", err
+		return nil, fmt.Errorf("error getting accountuuid after account add: %w", err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %s missing after account add", providedAccountDetails.email)
 	}
 
-	return token, nil
+	l.Debug(fmt.Sprintf("Local account added: %s", account.AccountUUID))
+
+	return account, nil
 }
 
 // Sign in to 1Password, returning the token.
-// If password is not provided, user will be prompted for it.
-func SignIn(ctx context.Context, account string, providedAccountDetails *AccountDetails) (string, error) {
+func (c *OnePasswordClient) signInAccount(ctx context.Context, account string, providedAccountDetails *AccountDetails) (string, error) {
 	l := ctxzap.Extract(ctx)
 
-	var (
-		out    bytes.Buffer
-		stderr bytes.Buffer
-		err    error
-		pipeIn io.WriteCloser
-	)
+	args := []string{"signin", "--account", account, "--raw"}
+	stdin := []byte(providedAccountDetails.password + "\n")
 
-	cmd := exec.CommandContext(ctx, "op", "signin", "--account", account, "--raw")
-
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-
-	if pipeIn, err = cmd.StdinPipe(); err != nil {
-		return "", err
-	}
-
-	if _, err = fmt.Fprintf(pipeIn, "%s\n", providedAccountDetails.password); err != nil {
-		return "", err
-	}
-
-	if err = pipeIn.Close(); err != nil {
-		return "", err
+	res, err := c.run(ctx, args, stdin, c.configEnv())
+	if err != nil {
+		return "", fmt.Errorf("error executing command: %w", err)
 	}
 
-	if err = cmd.Run(); err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			l.Error(
-				"error executing 'op signin --raw' command",
-				zap.Error(err),
-				zap.String("stderr", string(exitErr.Stderr)),
-				zap.Int("exit_code", exitErr.ExitCode()),
-			)
-		}
-		return "", fmt.Errorf("error executing command: %w", err)
+	if res.ExitCode != 0 {
+		l.Error(
+			"error executing 'op signin --raw' command",
+			zap.String("stderr", string(res.Stderr)),
+			zap.Int("exit_code", res.ExitCode),
+		)
+		return "", fmt.Errorf("error executing command: %w", newCommandError(res))
 	}
 
 	l.Debug("SignIn Completed")
 
-	return out.String(), nil
+	return strings.TrimSpace(string(res.Stdout)), nil
 }
 
 // GetSignedInAccount gets information about the signed in account.
@@ -238,6 +272,10 @@ func (c *OnePasswordClient) GetSignedInAccount(ctx context.Context) (AuthRespons
 
 // GetAccount gets information about the account.
 func (c *OnePasswordClient) GetAccount(ctx context.Context) (Account, error) {
+	if c.scim != nil && !c.HasCLI() {
+		return c.scim.Account(), nil
+	}
+
 	args := []string{"account", "get"}
 
 	var res Account
@@ -251,6 +289,10 @@ func (c *OnePasswordClient) GetAccount(ctx context.Context) (Account, error) {
 
 // ListUsers lists all users in the account.
 func (c *OnePasswordClient) ListUsers(ctx context.Context) ([]User, error) {
+	if c.scim != nil {
+		return c.scim.ListUsers(ctx)
+	}
+
 	args := []string{"user", "list"}
 
 	var res []User
@@ -262,8 +304,102 @@ func (c *OnePasswordClient) ListUsers(ctx context.Context) ([]User, error) {
 	return res, nil
 }
 
+// ProvisionUser invites a user to the account. 1Password emails the invitation, and the user enrols themselves.
+func (c *OnePasswordClient) ProvisionUser(ctx context.Context, email, name string) (User, error) {
+	args := []string{"user", "provision", "--email", email, "--name", name}
+
+	var res User
+	err := c.executeCommand(ctx, args, &res)
+	if err != nil {
+		return User{}, fmt.Errorf("error provisioning user: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetUser gets a user by ID or email.
+func (c *OnePasswordClient) GetUser(ctx context.Context, user string) (User, error) {
+	args := []string{"user", "get", user}
+
+	var res User
+	err := c.executeCommand(ctx, args, &res)
+	if err != nil {
+		return User{}, fmt.Errorf("error getting user: %w", err)
+	}
+
+	return res, nil
+}
+
+// SuspendUser suspends a user. With a positive deauthorizeAfter, the devices of the user are deauthorized once it has passed.
+func (c *OnePasswordClient) SuspendUser(ctx context.Context, user string, deauthorizeAfter time.Duration) error {
+	args := []string{"user", "suspend", user}
+	if deauthorizeAfter > 0 {
+		args = append(args, "--deauthorize-devices-after", opDuration(deauthorizeAfter))
+	}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error suspending user: %w", err)
+	}
+
+	return nil
+}
+
+// opDuration formats d in the largest whole unit of hours, minutes or seconds, such as 30m or 24h, as op documents durations.
+// Like op, it rounds d down to seconds.
+func opDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+// ReactivateUser reactivates a suspended user.
+func (c *OnePasswordClient) ReactivateUser(ctx context.Context, user string) error {
+	args := []string{"user", "reactivate", user}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error reactivating user: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmUser confirms a user who has accepted their invitation.
+func (c *OnePasswordClient) ConfirmUser(ctx context.Context, user string) error {
+	args := []string{"user", "confirm", user}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error confirming user: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUser removes a user from the account.
+func (c *OnePasswordClient) DeleteUser(ctx context.Context, user string) error {
+	args := []string{"user", "delete", user}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+
+	return nil
+}
+
 // ListGroups lists all groups in the account.
 func (c *OnePasswordClient) ListGroups(ctx context.Context) ([]Group, error) {
+	if c.scim != nil {
+		return c.scim.ListGroups(ctx)
+	}
+
 	args := []string{"group", "list"}
 
 	var res []Group
@@ -275,8 +411,53 @@ func (c *OnePasswordClient) ListGroups(ctx context.Context) ([]Group, error) {
 	return res, nil
 }
 
+// GetGroup gets a group by ID or name.
+func (c *OnePasswordClient) GetGroup(ctx context.Context, group string) (Group, error) {
+	args := []string{"group", "get", group}
+
+	var res Group
+	err := c.executeCommand(ctx, args, &res)
+	if err != nil {
+		return Group{}, fmt.Errorf("error getting group: %w", err)
+	}
+
+	return res, nil
+}
+
+// CreateGroup creates a group. The description may be empty.
+func (c *OnePasswordClient) CreateGroup(ctx context.Context, name, description string) (Group, error) {
+	args := []string{"group", "create", name}
+	if description != "" {
+		args = append(args, "--description", description)
+	}
+
+	var res Group
+	err := c.executeCommand(ctx, args, &res)
+	if err != nil {
+		return Group{}, fmt.Errorf("error creating group: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteGroup deletes a group.
+func (c *OnePasswordClient) DeleteGroup(ctx context.Context, group string) error {
+	args := []string{"group", "delete", group}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting group: %w", err)
+	}
+
+	return nil
+}
+
 // ListGroupMembers lists all members of a group.
 func (c *OnePasswordClient) ListGroupMembers(ctx context.Context, group string) ([]User, error) {
+	if c.scim != nil {
+		return c.scim.ListGroupMembers(ctx, group)
+	}
+
 	args := []string{"group", "user", "list", group}
 
 	var res []User
@@ -301,8 +482,57 @@ func (c *OnePasswordClient) ListVaults(ctx context.Context) ([]Vault, error) {
 	return res, nil
 }
 
+// GetVault gets a vault by ID or name, along with the number of items in it.
+func (c *OnePasswordClient) GetVault(ctx context.Context, vault string) (Vault, error) {
+	args := []string{"vault", "get", vault}
+
+	var res Vault
+	err := c.executeCommand(ctx, args, &res)
+	if err != nil {
+		return Vault{}, fmt.Errorf("error getting vault: %w", err)
+	}
+
+	return res, nil
+}
+
+// CreateVault creates a vault. The description and icon may be empty.
+// allowAdminsToManage lets the Administrators group manage the vault.
+func (c *OnePasswordClient) CreateVault(ctx context.Context, name, description, icon string, allowAdminsToManage bool) (Vault, error) {
+	args := []string{"vault", "create", name, "--allow-admins-to-manage", strconv.FormatBool(allowAdminsToManage)}
+	if description != "" {
+		args = append(args, "--description", description)
+	}
+	if icon != "" {
+		args = append(args, "--icon", icon)
+	}
+
+	var res Vault
+	err := c.executeCommand(ctx, args, &res)
+	if err != nil {
+		return Vault{}, fmt.Errorf("error creating vault: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteVault deletes a vault along with its items.
+func (c *OnePasswordClient) DeleteVault(ctx context.Context, vault string) error {
+	args := []string{"vault", "delete", vault}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error deleting vault: %w", err)
+	}
+
+	return nil
+}
+
 // ListVaultGroups lists all groups that have access to a vault.
 func (c *OnePasswordClient) ListVaultGroups(ctx context.Context, vaultId string) ([]Group, error) {
+	if !c.Supports(CapabilityVaultGroups) {
+		return nil, nil
+	}
+
 	args := []string{"vault", "group", "list", vaultId}
 
 	var res []Group
@@ -314,7 +544,10 @@ func (c *OnePasswordClient) ListVaultGroups(ctx context.Context, vaultId string)
 	return res, nil
 }
 
-// ListVaultMembers lists all users that have access to a vault.
+// ListVaultMembers lists the users granted access to a vault directly, along with their direct permissions.
+// op vault user list leaves out users who only reach the vault through a group, and the permissions users inherit from groups,
+// which op vault group list reports per group and ListVaultGroups lists. op vault user revoke goes by the same direct grants,
+// failing with "the accessor doesn't have any permissions" for access inherited from a group.
 func (c *OnePasswordClient) ListVaultMembers(ctx context.Context, vaultId string) ([]User, error) {
 	args := []string{"vault", "user", "list", vaultId}
 
@@ -327,22 +560,43 @@ func (c *OnePasswordClient) ListVaultMembers(ctx context.Context, vaultId string
 	return res, nil
 }
 
-// AddUserToGroup adds user to group.
+// AddUserToGroup grants user a role in group, member or manager.
+// op only makes members managers, so a user who is not in the group must be granted the member role first.
 func (c *OnePasswordClient) AddUserToGroup(ctx context.Context, group, role, user string) error {
+	if c.scim != nil {
+		return c.scim.AddUserToGroup(ctx, group, role, user)
+	}
+
 	args := []string{"group", "user", "grant", "--group", group, "--role", role, "--user", user}
+	if !c.Supports(CapabilityGroupRoles) {
+		if role == "manager" {
+			return fmt.Errorf("error adding user as a manager: group roles need op %s or newer: %w", capabilitySince[CapabilityGroupRoles], ErrUnsupportedVersion)
+		}
+		args = []string{"group", "user", "grant", "--group", group, "--user", user}
+	}
 
 	err := c.executeCommand(ctx, args, nil)
 	if err != nil {
-		return fmt.Errorf("error adding user as a member: %w", err)
+		return fmt.Errorf("error adding user as a %s: %w", role, err)
 	}
 
-	// role can either member or manager but in order for user to be a manager the member role needs to be assigned first.
-	// so we execute the command once more in order for member to become a manager.
-	if role == "manager" {
-		err := c.executeCommand(ctx, args, nil)
-		if err != nil {
-			return fmt.Errorf("error adding user as a manager: %w", err)
-		}
+	return nil
+}
+
+// DemoteGroupManager makes a manager of group a member again by granting them the member role, keeping them in the group.
+func (c *OnePasswordClient) DemoteGroupManager(ctx context.Context, group, user string) error {
+	if c.scim != nil {
+		return fmt.Errorf("error demoting group manager: the SCIM bridge cannot change group roles")
+	}
+	if !c.Supports(CapabilityGroupRoles) {
+		return fmt.Errorf("error demoting group manager: group roles need op %s or newer: %w", capabilitySince[CapabilityGroupRoles], ErrUnsupportedVersion)
+	}
+
+	args := []string{"group", "user", "grant", "--group", group, "--role", "member", "--user", user}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error demoting group manager: %w", err)
 	}
 
 	return nil
@@ -350,6 +604,10 @@ func (c *OnePasswordClient) AddUserToGroup(ctx context.Context, group, role, use
 
 // RemoveUserFromGroup removes user from group.
 func (c *OnePasswordClient) RemoveUserFromGroup(ctx context.Context, group, user string) error {
+	if c.scim != nil {
+		return c.scim.RemoveUserFromGroup(ctx, group, user)
+	}
+
 	args := []string{"group", "user", "revoke", "--group", group, "--user", user}
 
 	err := c.executeCommand(ctx, args, nil)
@@ -387,32 +645,41 @@ func (c *OnePasswordClient) RemoveUserFromVault(ctx context.Context, vault, user
 	return nil
 }
 
-func (c *OnePasswordClient) executeCommand(ctx context.Context, args []string, res interface{}) error {
-	l := ctxzap.Extract(ctx)
+// AddGroupToVault grants a group permissions on a vault.
+func (c *OnePasswordClient) AddGroupToVault(ctx context.Context, vault, group, permissions string) error {
+	if !c.Supports(CapabilityVaultGroups) {
+		return fmt.Errorf("error adding group to vault: vault groups need op %s or newer: %w", capabilitySince[CapabilityVaultGroups], ErrUnsupportedVersion)
+	}
 
-	defaultArgs := []string{"--format=json"}
+	args := []string{"vault", "group", "grant", "--vault", vault, "--group", group, "--permissions", permissions}
+
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error adding group to vault: %w", err)
+	}
 
-	if c.authType == "user" {
-		args = append(args, []string{"--session", c.token}...)
+	return nil
+}
+
+// RemoveGroupFromVault revokes permissions of a group on a vault.
+func (c *OnePasswordClient) RemoveGroupFromVault(ctx context.Context, vault, group, permissions string) error {
+	if !c.Supports(CapabilityVaultGroups) {
+		return fmt.Errorf("error removing group from vault: vault groups need op %s or newer: %w", capabilitySince[CapabilityVaultGroups], ErrUnsupportedVersion)
 	}
 
-	defaultArgs = append(args, defaultArgs...)
+	args := []string{"vault", "group", "revoke", "--vault", vault, "--group", group, "--permissions", permissions}
 
-	cmd := exec.CommandContext(ctx, "op", defaultArgs...)
-	output, err := cmd.Output()
-	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			l.Error(
-				"error executing command",
-				zap.Error(err),
-				zap.String("stderr", string(exitErr.Stderr)),
-				zap.String("stdout", string(output)),
-				zap.Int("exit_code", exitErr.ExitCode()),
-				zap.Strings("command_args", cmd.Args),
-			)
-		}
+	err := c.executeCommand(ctx, args, nil)
+	if err != nil {
+		return fmt.Errorf("error removing group from vault: %w", err)
+	}
+
+	return nil
+}
 
+func (c *OnePasswordClient) executeCommand(ctx context.Context, args []string, res interface{}) error {
+	stdout, err := c.executeCached(ctx, args)
+	if err != nil {
 		return fmt.Errorf("error: %w", err)
 	}
 
@@ -420,9 +687,87 @@ func (c *OnePasswordClient) executeCommand(ctx context.Context, args []string, r
 		return nil
 	}
 
-	if err := json.Unmarshal(output, &res); err != nil {
+	if err := json.Unmarshal(stdout, &res); err != nil {
 		return fmt.Errorf("error unmarshalling response: %w", err)
 	}
 
 	return nil
 }
+
+// executeCached serves reads from the cache, if enabled, and clears it after writes.
+func (c *OnePasswordClient) executeCached(ctx context.Context, args []string) ([]byte, error) {
+	if c.cache == nil {
+		out, err := c.executeWithRetry(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return out.Stdout, nil
+	}
+
+	if isReadCommand(args) {
+		return c.cache.get(ctx, args, func() ([]byte, error) {
+			out, err := c.executeWithRetry(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			return out.Stdout, nil
+		})
+	}
+
+	// A failed write may still have changed something, so the cache is cleared either way.
+	defer c.cache.invalidate()
+
+	out, err := c.executeWithRetry(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return out.Stdout, nil
+}
+
+// executeOnce runs a command, signing in again and retrying once if the session has expired.
+func (c *OnePasswordClient) executeOnce(ctx context.Context, args []string) (*CommandResult, error) {
+	token := c.sessionToken()
+
+	out, err := c.runCommand(ctx, args, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if out.ExitCode != 0 && c.canSignIn() && isSessionExpired(out.Stderr) {
+		if err := c.refreshSession(ctx, token); err != nil {
+			return nil, fmt.Errorf("error signing in after session expired: %w", err)
+		}
+
+		return c.runCommand(ctx, args, c.sessionToken())
+	}
+
+	return out, nil
+}
+
+func (c *OnePasswordClient) runCommand(ctx context.Context, args []string, token string) (*CommandResult, error) {
+	l := ctxzap.Extract(ctx)
+
+	defaultArgs := []string{"--format=json"}
+	if c.account != "" {
+		defaultArgs = append(defaultArgs, "--account", c.account)
+	}
+
+	defaultArgs = append(args, defaultArgs...)
+
+	out, err := c.run(ctx, defaultArgs, nil, c.commandEnv(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if out.ExitCode != 0 {
+		l.Error(
+			"error executing command",
+			zap.String("stderr", string(out.Stderr)),
+			zap.String("stdout", c.redactor.redact(string(out.Stdout))),
+			zap.Int("exit_code", out.ExitCode),
+			zap.Strings("command_args", c.redactor.redactAll(defaultArgs)),
+		)
+	}
+
+	return out, nil
+}
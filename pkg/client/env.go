@@ -0,0 +1,62 @@
+package onepassword
+
+import (
+	"fmt"
+	"os"
+)
+
+// NewConfigDir creates a private op configuration directory, accessible only to the current user.
+func NewConfigDir() (string, error) {
+	dir, err := os.MkdirTemp("", "baton-1password-op-")
+	if err != nil {
+		return "", fmt.Errorf("error creating op config directory: %w", err)
+	}
+	return dir, nil
+}
+
+// WithConfigDir keeps the op configuration of the client, such as the accounts it adds, in dir instead of ~/.config/op.
+// The client owns dir and removes it on Close.
+func WithConfigDir(dir string) Option {
+	return func(c *OnePasswordClient) {
+		c.configDir = dir
+	}
+}
+
+// configEnv returns the environment that points op at the configuration directory of the client.
+func (c *OnePasswordClient) configEnv() []string {
+	if c.configDir == "" {
+		return nil
+	}
+	return []string{"OP_CONFIG_DIR=" + c.configDir}
+}
+
+// commandEnv returns the environment of a command run with token.
+// Credentials are passed to each command instead of being set on the connector process or on the command line.
+func (c *OnePasswordClient) commandEnv(token string) []string {
+	env := c.configEnv()
+	if token == "" {
+		return env
+	}
+
+	switch c.authType {
+	case "user":
+		// op looks the session up by the ID of the signed in user.
+		if user := c.sessionUserID(); user != "" {
+			env = append(env, "OP_SESSION_"+user+"="+token)
+		}
+	default:
+		env = append(env, "OP_SERVICE_ACCOUNT_TOKEN="+token)
+	}
+
+	return env
+}
+
+func (c *OnePasswordClient) removeConfigDir() error {
+	if c.configDir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(c.configDir); err != nil {
+		return fmt.Errorf("error removing op config directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package onepassword
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Failure classes of the 1Password CLI, matched with errors.Is against errors returned by the client.
+var (
+	ErrNotFound                 = errors.New("not found")
+	ErrPermissionDenied         = errors.New("permission denied")
+	ErrInheritedGrant           = errors.New("inherited grant cannot be revoked")
+	ErrSessionExpired           = errors.New("session expired")
+	ErrRateLimited              = errors.New("rate limited")
+	ErrUnavailable              = errors.New("temporarily unavailable")
+	ErrAlreadyMember            = errors.New("already a member")
+	ErrServiceAccountNotAllowed = errors.New("not allowed for service accounts")
+	ErrUnsupportedVersion       = errors.New("not supported by this op version")
+	ErrBinaryMismatch           = errors.New("op executable does not match the expected digest")
+)
+
+// opErrorClasses maps fragments of op error messages to failure classes.
+// Matching is case insensitive and the first matching class wins, so more specific classes come first.
+var opErrorClasses = []struct {
+	kind      error
+	fragments []string
+}{
+	{ErrSessionExpired, []string{
+		"you are not currently signed in",
+		"session expired",
+		"invalid session token",
+		"authentication required",
+	}},
+	{ErrRateLimited, []string{
+		"too many requests",
+		"rate limit",
+		"(429)",
+	}},
+	// Network and server side failures that usually go away on their own.
+	{ErrUnavailable, []string{
+		"service unavailable",
+		"temporarily unavailable",
+		"bad gateway",
+		"gateway timeout",
+		"internal server error",
+		"(500)",
+		"(502)",
+		"(503)",
+		"(504)",
+		"connection reset",
+		"connection refused",
+		"i/o timeout",
+		"tls handshake timeout",
+		"unexpected eof",
+		"no such host",
+	}},
+	{ErrServiceAccountNotAllowed, []string{
+		"not supported for service accounts",
+		"not available to service accounts",
+		"service accounts can't",
+		"service accounts cannot",
+	}},
+	// Revoking a user that only has access to a vault through a group.
+	{ErrInheritedGrant, []string{
+		"the accessor doesn't have any permissions",
+	}},
+	{ErrAlreadyMember, []string{
+		"already a member",
+	}},
+	{ErrPermissionDenied, []string{
+		"you do not have permission",
+		"doesn't have permission",
+		"permission denied",
+		"not authorized",
+		"forbidden",
+		"(403)",
+	}},
+	{ErrNotFound, []string{
+		"isn't a user",
+		"isn't a group",
+		"isn't a vault",
+		"isn't a member",
+		"isn't an item",
+		"not found",
+		"no such",
+		"(404)",
+	}},
+}
+
+var opErrorPrefix = regexp.MustCompile(`^\[ERROR\] \d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+// CommandError is returned when the 1Password CLI exits with a non-zero code.
+type CommandError struct {
+	ExitCode int
+	Stderr   string
+	// Kind is the failure class parsed from Stderr, nil if the failure is not recognised.
+	Kind error
+}
+
+func (e *CommandError) Error() string {
+	if msg := e.Message(); msg != "" {
+		return fmt.Sprintf("exit status %d: %s", e.ExitCode, msg)
+	}
+	return fmt.Sprintf("exit status %d", e.ExitCode)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Kind
+}
+
+// Message returns the op error message without its log prefix.
+func (e *CommandError) Message() string {
+	return opErrorPrefix.ReplaceAllString(strings.TrimSpace(e.Stderr), "")
+}
+
+func newCommandError(res *CommandResult) *CommandError {
+	return &CommandError{
+		ExitCode: res.ExitCode,
+		Stderr:   string(res.Stderr),
+		Kind:     classifyError(string(res.Stderr)),
+	}
+}
+
+func classifyError(stderr string) error {
+	stderr = strings.ToLower(stderr)
+	for _, class := range opErrorClasses {
+		for _, fragment := range class.fragments {
+			if strings.Contains(stderr, fragment) {
+				return class.kind
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package onepassword
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandErrorKind(t *testing.T) {
+	tests := []struct {
+		stderr string
+		kind   error
+	}{
+		{`[ERROR] 2024/05/01 12:00:00 "bob@example.com" isn't a user in this account. Specify the user with their UUID, email address, or name.`, ErrNotFound},
+		{`[ERROR] 2024/05/01 12:00:00 You do not have permission to perform this action`, ErrPermissionDenied},
+		{`[ERROR] 2024/05/01 12:00:00 the accessor doesn't have any permissions`, ErrInheritedGrant},
+		{`[ERROR] 2024/05/01 12:00:00 You are not currently signed in. Please run ` + "`op signin --help`" + ` for instructions`, ErrSessionExpired},
+		{`[ERROR] 2024/05/01 12:00:00 Too many requests. Try again later (429)`, ErrRateLimited},
+		{`[ERROR] 2024/05/01 12:00:00 "alice@example.com" is already a member of the group`, ErrAlreadyMember},
+		{`[ERROR] 2024/05/01 12:00:00 this command is not supported for service accounts`, ErrServiceAccountNotAllowed},
+		{`[ERROR] 2024/05/01 12:00:00 something unexpected happened`, nil},
+	}
+
+	for _, tt := range tests {
+		err := newCommandError(&CommandResult{Stderr: []byte(tt.stderr), ExitCode: 1})
+		if tt.kind == nil {
+			require.NoError(t, errors.Unwrap(err), tt.stderr)
+			continue
+		}
+		require.ErrorIs(t, err, tt.kind, tt.stderr)
+	}
+}
+
+func TestCommandErrorMessage(t *testing.T) {
+	err := newCommandError(&CommandResult{Stderr: []byte("[ERROR] 2024/05/01 12:00:00 the accessor doesn't have any permissions\n"), ExitCode: 1})
+	require.Equal(t, "the accessor doesn't have any permissions", err.Message())
+	require.EqualError(t, err, "exit status 1: the accessor doesn't have any permissions")
+
+	err = newCommandError(&CommandResult{ExitCode: 2})
+	require.EqualError(t, err, "exit status 2")
+}
@@ -0,0 +1,133 @@
+package onepassword
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+)
+
+const DefaultEventsAPIURL = "https://events.1password.com"
+
+// EventsAPIClient reads sign-in attempts, item usages and audit events from the 1Password Events API.
+type EventsAPIClient struct {
+	baseURL *url.URL
+	token   string
+	client  *uhttp.BaseHttpClient
+}
+
+type eventsError struct {
+	Err struct {
+		Message string `json:"Message"`
+	} `json:"Error"`
+}
+
+func (e *eventsError) Message() string {
+	return e.Err.Message
+}
+
+// eventsRequest is either a reset cursor (limit and start time) or a continuation cursor.
+type eventsRequest struct {
+	Limit     int    `json:"limit,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
+	Cursor    string `json:"cursor,omitempty"`
+}
+
+// NewEventsAPIClient returns a client for the Events API at apiURL authenticated with an Events API bearer token.
+func NewEventsAPIClient(ctx context.Context, apiURL string, token string) (*EventsAPIClient, error) {
+	if apiURL == "" {
+		apiURL = DefaultEventsAPIURL
+	}
+
+	baseURL, err := url.Parse(strings.TrimSuffix(apiURL, "/") + "/api/v1/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid events api url: %w", err)
+	}
+
+	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+
+	client, err := uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+
+	return &EventsAPIClient{
+		baseURL: baseURL,
+		token:   token,
+		client:  client,
+	}, nil
+}
+
+// ListSignInAttempts returns a page of sign-in attempts.
+// An empty cursor starts a new stream at startTime.
+func (e *EventsAPIClient) ListSignInAttempts(ctx context.Context, cursor string, limit int, startTime time.Time) (*EventsPage[SignInAttempt], error) {
+	res, err := listEvents[SignInAttempt](ctx, e, "signinattempts", cursor, limit, startTime)
+	if err != nil {
+		return nil, fmt.Errorf("error listing sign-in attempts: %w", err)
+	}
+
+	return res, nil
+}
+
+// ListItemUsages returns a page of item usages.
+// An empty cursor starts a new stream at startTime.
+func (e *EventsAPIClient) ListItemUsages(ctx context.Context, cursor string, limit int, startTime time.Time) (*EventsPage[ItemUsage], error) {
+	res, err := listEvents[ItemUsage](ctx, e, "itemusages", cursor, limit, startTime)
+	if err != nil {
+		return nil, fmt.Errorf("error listing item usages: %w", err)
+	}
+
+	return res, nil
+}
+
+// ListAuditEvents returns a page of audit events.
+// An empty cursor starts a new stream at startTime.
+func (e *EventsAPIClient) ListAuditEvents(ctx context.Context, cursor string, limit int, startTime time.Time) (*EventsPage[AuditEvent], error) {
+	res, err := listEvents[AuditEvent](ctx, e, "auditevents", cursor, limit, startTime)
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit events: %w", err)
+	}
+
+	return res, nil
+}
+
+func listEvents[T any](ctx context.Context, e *EventsAPIClient, path string, cursor string, limit int, startTime time.Time) (*EventsPage[T], error) {
+	body := eventsRequest{Cursor: cursor}
+	if cursor == "" {
+		body.Limit = limit
+		if !startTime.IsZero() {
+			body.StartTime = startTime.UTC().Format(time.RFC3339)
+		}
+	}
+
+	req, err := e.client.NewRequest(ctx, http.MethodPost, e.baseURL.JoinPath(path),
+		uhttp.WithBearerToken(e.token),
+		uhttp.WithAccept("application/json"),
+		uhttp.WithJSONBody(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var res EventsPage[T]
+	resp, err := e.client.Do(req,
+		uhttp.WithJSONResponse(&res),
+		uhttp.WithErrorResponse(&eventsError{}),
+	)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,14 @@
+package onepassword_test
+
+import (
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+)
+
+// newTestEmulator returns an emulator with the account ACCOUNT1 (example.1password.com), the user Alice Smith (U1)
+// and the group Engineering (G1).
+func newTestEmulator() *optest.Emulator {
+	return optest.NewEmulator(onepassword.Account{BaseType: onepassword.BaseType{ID: "ACCOUNT1", Name: "Example"}, Domain: "example"}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}, Email: "alice@example.com"}).
+		AddGroup(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}})
+}
@@ -1,5 +1,7 @@
 package onepassword
 
+import "time"
+
 type BaseType struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
@@ -40,13 +42,17 @@ type Group struct {
 	BaseType
 	Description string   `json:"description,omitempty"`
 	State       string   `json:"state"`
+	Type        string   `json:"type,omitempty"`
 	CreatedAt   string   `json:"created_at"`
 	Permissions []string `json:"permissions,omitempty"`
 }
 
 type Vault struct {
 	BaseType
-	ContentVersion int `json:"content_version"`
+	ContentVersion int    `json:"content_version"`
+	Description    string `json:"description,omitempty"`
+	// Items is the number of items in the vault, reported by op vault get only.
+	Items int `json:"items,omitempty"`
 }
 
 type AuthResponse struct {
@@ -56,3 +62,72 @@ type AuthResponse struct {
 	AccountUUID string `json:"account_uuid"`
 	Shorthand   string `json:"shorthand"`
 }
+
+type EventsUser struct {
+	UUID  string `json:"uuid"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type EventsClientInfo struct {
+	AppName         string `json:"app_name"`
+	AppVersion      string `json:"app_version"`
+	PlatformName    string `json:"platform_name"`
+	PlatformVersion string `json:"platform_version"`
+	OSName          string `json:"os_name"`
+	OSVersion       string `json:"os_version"`
+	IPAddress       string `json:"ip_address"`
+}
+
+type SignInAttempt struct {
+	UUID        string           `json:"uuid"`
+	SessionUUID string           `json:"session_uuid"`
+	Timestamp   time.Time        `json:"timestamp"`
+	Category    string           `json:"category"`
+	Type        string           `json:"type"`
+	Country     string           `json:"country"`
+	TargetUser  EventsUser       `json:"target_user"`
+	Client      EventsClientInfo `json:"client"`
+}
+
+type ItemUsage struct {
+	UUID        string           `json:"uuid"`
+	Timestamp   time.Time        `json:"timestamp"`
+	UsedVersion int              `json:"used_version"`
+	VaultUUID   string           `json:"vault_uuid"`
+	ItemUUID    string           `json:"item_uuid"`
+	Action      string           `json:"action"`
+	User        EventsUser       `json:"user"`
+	Client      EventsClientInfo `json:"client"`
+}
+
+type AuditEvent struct {
+	UUID         string     `json:"uuid"`
+	Timestamp    time.Time  `json:"timestamp"`
+	ActorUUID    string     `json:"actor_uuid"`
+	ActorDetails EventsUser `json:"actor_details"`
+	Action       string     `json:"action"`
+	ObjectType   string     `json:"object_type"`
+	ObjectUUID   string     `json:"object_uuid"`
+	AuxID        int        `json:"aux_id"`
+	AuxUUID      string     `json:"aux_uuid"`
+	AuxDetails   EventsUser `json:"aux_details"`
+	AuxInfo      string     `json:"aux_info"`
+}
+
+type EventsPage[T any] struct {
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+	Items   []T    `json:"items"`
+}
+
+// RateLimit is one of the request quotas reported by `op service-account ratelimit`.
+type RateLimit struct {
+	Type      string `json:"type"`
+	Action    string `json:"action"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+	// Reset is the number of seconds until the quota window resets, 0 if no window is in progress.
+	Reset int64 `json:"reset"`
+}
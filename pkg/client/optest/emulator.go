@@ -0,0 +1,907 @@
+package optest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+)
+
+const (
+	roleMember  = "MEMBER"
+	roleManager = "MANAGER"
+)
+
+// Emulator is a stateful, in-memory stand-in for the subset of the 1Password CLI used by the connector.
+// Mutating commands update the account model, so a grant followed by a list reflects the change.
+type Emulator struct {
+	mu sync.Mutex
+
+	account onepassword.Account
+	whoami  onepassword.AuthResponse
+
+	users  []*onepassword.User
+	groups []*onepassword.Group
+	vaults []*onepassword.Vault
+
+	// group ID -> user ID -> role.
+	groupMembers map[string]map[string]string
+	// vault ID -> user ID -> directly granted permissions.
+	vaultUsers map[string]map[string][]string
+	// vault ID -> group ID -> permissions.
+	vaultGroups map[string]map[string][]string
+
+	// password enables user-mode sessions, see RequireSignIn.
+	password string
+	session  string
+	signIns  int
+	// OP_CONFIG_DIR values the account has been added to with `op account add`.
+	configDirs map[string]bool
+
+	// Reported by `op --version`, see SetVersion.
+	version string
+
+	// Service account quotas, see SetRateLimits.
+	rateLimits []onepassword.RateLimit
+
+	calls []Call
+}
+
+// rawOutput is printed as is instead of being encoded as JSON, like `op --raw` output.
+type rawOutput string
+
+func NewEmulator(account onepassword.Account) *Emulator {
+	return &Emulator{
+		account: account,
+		whoami: onepassword.AuthResponse{
+			URL:         fmt.Sprintf("https://%s.1password.com", account.Domain),
+			AccountUUID: account.ID,
+		},
+		groupMembers: make(map[string]map[string]string),
+		vaultUsers:   make(map[string]map[string][]string),
+		vaultGroups:  make(map[string]map[string][]string),
+		configDirs:   make(map[string]bool),
+		version:      "2.30.0",
+	}
+}
+
+// AddUser adds a user to the account and returns the emulator for chaining.
+func (e *Emulator) AddUser(user onepassword.User) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if user.State == "" {
+		user.State = "ACTIVE"
+	}
+	if user.Type == "" {
+		user.Type = roleMember
+	}
+	e.users = append(e.users, &user)
+	if e.whoami.UserUUID == "" {
+		e.whoami.UserUUID = user.ID
+		e.whoami.Email = user.Email
+	}
+
+	return e
+}
+
+// AddGroup adds a group to the account and returns the emulator for chaining.
+func (e *Emulator) AddGroup(group onepassword.Group) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if group.State == "" {
+		group.State = "ACTIVE"
+	}
+	if group.Type == "" {
+		group.Type = "USER_DEFINED"
+	}
+	e.groups = append(e.groups, &group)
+
+	return e
+}
+
+// AddVault adds a vault to the account and returns the emulator for chaining.
+func (e *Emulator) AddVault(vault onepassword.Vault) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.vaults = append(e.vaults, &vault)
+
+	return e
+}
+
+// SetVaultItems sets the number of items in a vault, as items added or removed in 1Password would.
+func (e *Emulator) SetVaultItems(vaultID string, items int) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, v := range e.vaults {
+		if v.ID == vaultID {
+			v.Items = items
+		}
+	}
+
+	return e
+}
+
+// SetGroupMember sets the role ("MEMBER" or "MANAGER") of a user in a group.
+func (e *Emulator) SetGroupMember(groupID, userID, role string) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.groupMembers[groupID] == nil {
+		e.groupMembers[groupID] = make(map[string]string)
+	}
+	e.groupMembers[groupID][userID] = role
+
+	return e
+}
+
+// SetVaultUserPermissions sets the permissions granted directly to a user on a vault.
+func (e *Emulator) SetVaultUserPermissions(vaultID, userID string, permissions ...string) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.vaultUsers[vaultID] == nil {
+		e.vaultUsers[vaultID] = make(map[string][]string)
+	}
+	e.vaultUsers[vaultID][userID] = permissions
+
+	return e
+}
+
+// SetVaultGroupPermissions sets the permissions granted to a group on a vault.
+func (e *Emulator) SetVaultGroupPermissions(vaultID, groupID string, permissions ...string) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.vaultGroups[vaultID] == nil {
+		e.vaultGroups[vaultID] = make(map[string][]string)
+	}
+	e.vaultGroups[vaultID][groupID] = permissions
+
+	return e
+}
+
+// RequireSignIn makes the emulator behave like a user-mode CLI: commands fail as signed out
+// unless their OP_SESSION_<user ID> variable holds the token of the current session, which `op signin` issues for password.
+// The account must first be added with `op account add` to the configuration directory named by OP_CONFIG_DIR.
+func (e *Emulator) RequireSignIn(password string) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.password = password
+
+	return e
+}
+
+// ExpireSession invalidates the current session token, as 30 minutes of inactivity would.
+func (e *Emulator) ExpireSession() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.session = ""
+}
+
+// SignIns returns the number of successful `op signin` invocations.
+func (e *Emulator) SignIns() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.signIns
+}
+
+// SetVersion changes the release reported by `op --version`, 2.30.0 by default.
+func (e *Emulator) SetVersion(version string) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.version = version
+
+	return e
+}
+
+// SetRateLimits enables service account quotas, reported by `op service-account ratelimit`.
+// Every other command uses one request of the quotas that apply to it and fails as rate limited once one is spent.
+func (e *Emulator) SetRateLimits(limits ...onepassword.RateLimit) *Emulator {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rateLimits = limits
+
+	return e
+}
+
+// Calls returns every invocation received so far.
+func (e *Emulator) Calls() []Call {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Call(nil), e.calls...)
+}
+
+type emulatorError struct {
+	msg string
+}
+
+func (e *emulatorError) Error() string {
+	return e.msg
+}
+
+func errorf(format string, args ...any) error {
+	return &emulatorError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Emulator) Run(ctx context.Context, args []string, stdin []byte, env []string) (*onepassword.CommandResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.calls = append(e.calls, Call{
+		Args:  append([]string(nil), args...),
+		Stdin: stdin,
+		Env:   append([]string(nil), env...),
+	})
+
+	positional, flags := parseArgs(args)
+
+	out, err := e.dispatch(positional, flags, stdin, parseEnv(env))
+	if err != nil {
+		var emuErr *emulatorError
+		if !errors.As(err, &emuErr) {
+			return nil, err
+		}
+		return &onepassword.CommandResult{
+			Stderr:   []byte(fmt.Sprintf("[ERROR] %s %s\n", time.Now().Format("2006/01/02 15:04:05"), emuErr.msg)),
+			ExitCode: 1,
+		}, nil
+	}
+
+	var stdout []byte
+	if raw, ok := out.(rawOutput); ok {
+		stdout = []byte(raw + "\n")
+	} else if out != nil {
+		if stdout, err = json.Marshal(out); err != nil {
+			return nil, err
+		}
+	}
+
+	return &onepassword.CommandResult{Stdout: stdout}, nil
+}
+
+func parseArgs(args []string) ([]string, map[string]string) {
+	var positional []string
+	flags := make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg, "--")
+		if k, v, ok := strings.Cut(name, "="); ok {
+			flags[k] = v
+			continue
+		}
+
+		switch name {
+		case "raw", "force", "version":
+			flags[name] = "true"
+		default:
+			if i+1 < len(args) {
+				flags[name] = args[i+1]
+				i++
+			}
+		}
+	}
+
+	return positional, flags
+}
+
+func parseEnv(env []string) map[string]string {
+	vars := make(map[string]string, len(env))
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+func (e *Emulator) dispatch(positional []string, flags map[string]string, stdin []byte, env map[string]string) (any, error) {
+	command := strings.Join(positional, " ")
+
+	if command == "" && flags["version"] == "true" {
+		return rawOutput(e.version), nil
+	}
+
+	switch command {
+	case "accounts list":
+		return e.listLocalAccounts(env["OP_CONFIG_DIR"]), nil
+	case "account add":
+		return nil, e.addLocalAccount(flags["address"], flags["email"], stdin, env)
+	case "signin":
+		return e.signIn(flags["account"], stdin, env["OP_CONFIG_DIR"])
+	case "service-account ratelimit":
+		return e.rateLimits, nil
+	}
+
+	if err := e.useRateLimits(positional); err != nil {
+		return nil, err
+	}
+
+	if e.password != "" && (e.session == "" || env["OP_SESSION_"+e.whoami.UserUUID] != e.session) {
+		return nil, errorf("You are not currently signed in. Please run `op signin --help` for instructions")
+	}
+
+	if account, ok := flags["account"]; ok && !e.isAccount(account) {
+		return nil, errorf("no account found for filter %s", account)
+	}
+
+	switch {
+	case command == "whoami":
+		return e.whoami, nil
+	case command == "account get":
+		return e.account, nil
+	case command == "user list":
+		return e.listUsers(), nil
+	case strings.HasPrefix(command, "user get "):
+		return e.getUser(positional[2])
+	case strings.HasPrefix(command, "user suspend "):
+		if after, ok := flags["deauthorize-devices-after"]; ok {
+			if _, err := time.ParseDuration(after); err != nil {
+				return nil, errorf("invalid argument %q for \"--deauthorize-devices-after\" flag", after)
+			}
+		}
+		return nil, e.setUserState(positional[2], "SUSPENDED", "ACTIVE")
+	case strings.HasPrefix(command, "user reactivate "):
+		return nil, e.setUserState(positional[2], "ACTIVE", "SUSPENDED")
+	case strings.HasPrefix(command, "user confirm "):
+		return nil, e.setUserState(positional[2], "ACTIVE", "PENDING")
+	case strings.HasPrefix(command, "user delete "):
+		return nil, e.deleteUser(positional[2])
+	case command == "user provision":
+		return e.provisionUser(flags["email"], flags["name"])
+	case command == "group list":
+		return e.listGroups(), nil
+	case strings.HasPrefix(command, "group get "):
+		return e.findGroup(positional[2])
+	case strings.HasPrefix(command, "group create "):
+		return e.createGroup(positional[2], flags["description"]), nil
+	case strings.HasPrefix(command, "group delete "):
+		return nil, e.deleteGroup(positional[2])
+	case strings.HasPrefix(command, "group user list "):
+		return e.listGroupMembers(positional[3])
+	case command == "group user grant":
+		return nil, e.grantGroupUser(flags["group"], flags["user"], flags["role"])
+	case command == "group user revoke":
+		return nil, e.revokeGroupUser(flags["group"], flags["user"])
+	case command == "vault list":
+		return e.listVaults(), nil
+	case strings.HasPrefix(command, "vault get "):
+		return e.findVault(positional[2])
+	case strings.HasPrefix(command, "vault create "):
+		return e.createVault(positional[2], flags["description"], flags["allow-admins-to-manage"])
+	case strings.HasPrefix(command, "vault delete "):
+		return nil, e.deleteVault(positional[2])
+	case strings.HasPrefix(command, "vault user list "):
+		return e.listVaultUsers(positional[3])
+	case command == "vault user grant":
+		return nil, e.grantVaultUser(flags["vault"], flags["user"], splitPermissions(flags["permissions"]))
+	case command == "vault user revoke":
+		return nil, e.revokeVaultUser(flags["vault"], flags["user"], splitPermissions(flags["permissions"]))
+	case command == "vault group grant":
+		return nil, e.grantVaultGroup(flags["vault"], flags["group"], splitPermissions(flags["permissions"]))
+	case command == "vault group revoke":
+		return nil, e.revokeVaultGroup(flags["vault"], flags["group"], splitPermissions(flags["permissions"]))
+	case strings.HasPrefix(command, "vault group list "):
+		return e.listVaultGroups(positional[3])
+	default:
+		return nil, errorf("unknown command %q for \"op\"", command)
+	}
+}
+
+func (e *Emulator) listLocalAccounts(configDir string) []onepassword.LocalAccountDetails {
+	if e.password != "" && !e.configDirs[configDir] {
+		return []onepassword.LocalAccountDetails{}
+	}
+
+	return []onepassword.LocalAccountDetails{
+		{
+			URL:         e.whoami.URL,
+			Email:       e.whoami.Email,
+			UserUUID:    e.whoami.UserUUID,
+			AccountUUID: e.account.ID,
+		},
+	}
+}
+
+// isAccount reports whether an --account filter names the emulated account, by ID, sign-in address or user ID.
+func (e *Emulator) isAccount(filter string) bool {
+	return filter == e.account.ID || filter == e.whoami.UserUUID ||
+		strings.TrimPrefix(filter, "https://") == strings.TrimPrefix(e.whoami.URL, "https://")
+}
+
+func (e *Emulator) addLocalAccount(address, email string, stdin []byte, env map[string]string) error {
+	if !e.isAccount(address) || email != e.whoami.Email {
+		return errorf("no account found at %s for %s", address, email)
+	}
+	if env["OP_SECRET_KEY"] == "" {
+		return errorf("a secret key is required, set OP_SECRET_KEY")
+	}
+	if strings.TrimSuffix(string(stdin), "\n") != e.password {
+		return errorf("authentication failed: incorrect password")
+	}
+
+	e.configDirs[env["OP_CONFIG_DIR"]] = true
+
+	return nil
+}
+
+func (e *Emulator) signIn(account string, stdin []byte, configDir string) (any, error) {
+	if account != e.account.ID || !e.configDirs[configDir] {
+		return nil, errorf("no account found for filter %q", account)
+	}
+	if strings.TrimSuffix(string(stdin), "\n") != e.password {
+		return nil, errorf("authentication failed: incorrect password")
+	}
+
+	e.signIns++
+	e.session = fmt.Sprintf("session-%d", e.signIns)
+
+	return rawOutput(e.session), nil
+}
+
+func (e *Emulator) useRateLimits(positional []string) error {
+	write := !slices.ContainsFunc(positional, func(arg string) bool {
+		return arg == "list" || arg == "get" || arg == "whoami"
+	})
+
+	applies := func(limit onepassword.RateLimit) bool {
+		return limit.Action == "read_write" || (limit.Action == "write") == write
+	}
+
+	for _, limit := range e.rateLimits {
+		if applies(limit) && limit.Remaining <= 0 {
+			return errorf("Too many requests. Your service account has reached its %s %s rate limit (429)", limit.Type, limit.Action)
+		}
+	}
+	for i := range e.rateLimits {
+		if applies(e.rateLimits[i]) {
+			e.rateLimits[i].Remaining--
+			e.rateLimits[i].Used++
+		}
+	}
+
+	return nil
+}
+
+func splitPermissions(permissions string) []string {
+	if permissions == "" {
+		return nil
+	}
+	return strings.Split(permissions, ",")
+}
+
+func (e *Emulator) findUser(ref string) (*onepassword.User, error) {
+	for _, u := range e.users {
+		if u.ID == ref || strings.EqualFold(u.Email, ref) {
+			return u, nil
+		}
+	}
+
+	var matches []*onepassword.User
+	for _, u := range e.users {
+		if u.Name == ref {
+			matches = append(matches, u)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errorf("%q isn't a user in this account. Specify the user with their UUID, email address, or name.", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, errorf("more than one user matches %q. Try again and specify the user by its ID or email address.", ref)
+	}
+}
+
+func (e *Emulator) findGroup(ref string) (*onepassword.Group, error) {
+	for _, g := range e.groups {
+		if g.ID == ref || g.Name == ref {
+			return g, nil
+		}
+	}
+	return nil, errorf("%q isn't a group in this account. Specify the group with its UUID or name.", ref)
+}
+
+func (e *Emulator) findVault(ref string) (*onepassword.Vault, error) {
+	for _, v := range e.vaults {
+		if v.ID == ref || v.Name == ref {
+			return v, nil
+		}
+	}
+	return nil, errorf("%q isn't a vault in this account. Specify the vault with its ID or name.", ref)
+}
+
+func (e *Emulator) listUsers() []onepassword.User {
+	rv := make([]onepassword.User, 0, len(e.users))
+	for _, u := range e.users {
+		rv = append(rv, *u)
+	}
+	return rv
+}
+
+// provisionUser invites a user, who stays pending until they accept the invitation.
+func (e *Emulator) provisionUser(email, name string) (*onepassword.User, error) {
+	if email == "" || name == "" {
+		return nil, errorf("both --email and --name are required")
+	}
+	for _, u := range e.users {
+		if strings.EqualFold(u.Email, email) {
+			return nil, errorf("a user with the email address %s already exists", email)
+		}
+	}
+
+	user := &onepassword.User{
+		BaseType: onepassword.BaseType{ID: e.newID("U", len(e.users)), Name: name},
+		Email:    email,
+		Type:     roleMember,
+		State:    "PENDING",
+	}
+	e.users = append(e.users, user)
+
+	return user, nil
+}
+
+func (e *Emulator) getUser(ref string) (*onepassword.User, error) {
+	user, err := e.findUser(ref)
+	if err != nil {
+		return nil, err
+	}
+	rv := *user
+	return &rv, nil
+}
+
+// setUserState moves a user to state, refusing users that are not in the from state.
+func (e *Emulator) setUserState(ref, state, from string) error {
+	user, err := e.findUser(ref)
+	if err != nil {
+		return err
+	}
+	if user.State != from {
+		return errorf("user %s is %s, expected %s", user.ID, user.State, from)
+	}
+	user.State = state
+	return nil
+}
+
+// deleteUser removes a user along with their group memberships and vault access.
+func (e *Emulator) deleteUser(ref string) error {
+	user, err := e.findUser(ref)
+	if err != nil {
+		return err
+	}
+
+	e.users = slices.DeleteFunc(e.users, func(u *onepassword.User) bool { return u.ID == user.ID })
+	for _, members := range e.groupMembers {
+		delete(members, user.ID)
+	}
+	for _, users := range e.vaultUsers {
+		delete(users, user.ID)
+	}
+
+	return nil
+}
+
+// newID returns an unused ID made of prefix and a number, starting after the n existing resources.
+func (e *Emulator) newID(prefix string, n int) string {
+	for {
+		n++
+		id := fmt.Sprintf("%s%d", prefix, n)
+		if !e.hasID(id) {
+			return id
+		}
+	}
+}
+
+func (e *Emulator) hasID(id string) bool {
+	return slices.ContainsFunc(e.users, func(u *onepassword.User) bool { return u.ID == id }) ||
+		slices.ContainsFunc(e.groups, func(g *onepassword.Group) bool { return g.ID == id }) ||
+		slices.ContainsFunc(e.vaults, func(v *onepassword.Vault) bool { return v.ID == id })
+}
+
+func (e *Emulator) listGroups() []onepassword.Group {
+	rv := make([]onepassword.Group, 0, len(e.groups))
+	for _, g := range e.groups {
+		rv = append(rv, *g)
+	}
+	return rv
+}
+
+func (e *Emulator) createGroup(name, description string) *onepassword.Group {
+	group := &onepassword.Group{
+		BaseType:    onepassword.BaseType{ID: e.newID("G", len(e.groups)), Name: name},
+		Description: description,
+		State:       "ACTIVE",
+		Type:        "USER_DEFINED",
+	}
+	e.groups = append(e.groups, group)
+
+	return group
+}
+
+// deleteGroup removes a group along with its memberships and vault access.
+func (e *Emulator) deleteGroup(ref string) error {
+	group, err := e.findGroup(ref)
+	if err != nil {
+		return err
+	}
+
+	e.groups = slices.DeleteFunc(e.groups, func(g *onepassword.Group) bool { return g.ID == group.ID })
+	delete(e.groupMembers, group.ID)
+	for _, groups := range e.vaultGroups {
+		delete(groups, group.ID)
+	}
+
+	return nil
+}
+
+func (e *Emulator) createVault(name, description, allowAdminsToManage string) (*onepassword.Vault, error) {
+	if allowAdminsToManage != "" && allowAdminsToManage != "true" && allowAdminsToManage != "false" {
+		return nil, errorf("invalid argument %q for \"--allow-admins-to-manage\" flag", allowAdminsToManage)
+	}
+
+	vault := &onepassword.Vault{
+		BaseType:    onepassword.BaseType{ID: e.newID("V", len(e.vaults)), Name: name},
+		Description: description,
+	}
+	e.vaults = append(e.vaults, vault)
+
+	return vault, nil
+}
+
+// deleteVault removes a vault along with its user and group access.
+func (e *Emulator) deleteVault(ref string) error {
+	vault, err := e.findVault(ref)
+	if err != nil {
+		return err
+	}
+
+	e.vaults = slices.DeleteFunc(e.vaults, func(v *onepassword.Vault) bool { return v.ID == vault.ID })
+	delete(e.vaultUsers, vault.ID)
+	delete(e.vaultGroups, vault.ID)
+
+	return nil
+}
+
+func (e *Emulator) listVaults() []onepassword.Vault {
+	rv := make([]onepassword.Vault, 0, len(e.vaults))
+	for _, v := range e.vaults {
+		rv = append(rv, *v)
+	}
+	return rv
+}
+
+func (e *Emulator) listGroupMembers(ref string) ([]onepassword.User, error) {
+	group, err := e.findGroup(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := []onepassword.User{}
+	for _, u := range e.users {
+		role, ok := e.groupMembers[group.ID][u.ID]
+		if !ok {
+			continue
+		}
+		member := *u
+		member.Role = role
+		rv = append(rv, member)
+	}
+
+	return rv, nil
+}
+
+// grantGroupUser mirrors the CLI behaviour where a user must be a member of a group
+// before they can be made a manager: granting the manager role to a non-member only adds them as a member.
+func (e *Emulator) grantGroupUser(groupRef, userRef, role string) error {
+	group, err := e.findGroup(groupRef)
+	if err != nil {
+		return err
+	}
+	user, err := e.findUser(userRef)
+	if err != nil {
+		return err
+	}
+
+	if e.groupMembers[group.ID] == nil {
+		e.groupMembers[group.ID] = make(map[string]string)
+	}
+
+	_, isMember := e.groupMembers[group.ID][user.ID]
+
+	switch strings.ToUpper(role) {
+	case "", roleMember:
+		e.groupMembers[group.ID][user.ID] = roleMember
+	case roleManager:
+		if !isMember {
+			e.groupMembers[group.ID][user.ID] = roleMember
+			return nil
+		}
+		e.groupMembers[group.ID][user.ID] = roleManager
+	default:
+		return errorf("invalid role %q. Valid roles are \"member\" and \"manager\"", role)
+	}
+
+	return nil
+}
+
+func (e *Emulator) revokeGroupUser(groupRef, userRef string) error {
+	group, err := e.findGroup(groupRef)
+	if err != nil {
+		return err
+	}
+	user, err := e.findUser(userRef)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := e.groupMembers[group.ID][user.ID]; !ok {
+		return errorf("%q isn't a member of the %q group", user.Email, group.Name)
+	}
+	delete(e.groupMembers[group.ID], user.ID)
+
+	return nil
+}
+
+// listVaultUsers returns the users granted access to a vault directly, along with their direct permissions.
+// Like the CLI, it leaves out access inherited through groups, which listVaultGroups reports.
+func (e *Emulator) listVaultUsers(ref string) ([]onepassword.User, error) {
+	vault, err := e.findVault(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := []onepassword.User{}
+	for _, u := range e.users {
+		perms, ok := e.vaultUsers[vault.ID][u.ID]
+		if !ok {
+			continue
+		}
+		member := *u
+		member.Permissions = append([]string(nil), perms...)
+		rv = append(rv, member)
+	}
+
+	return rv, nil
+}
+
+func (e *Emulator) listVaultGroups(ref string) ([]onepassword.Group, error) {
+	vault, err := e.findVault(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := []onepassword.Group{}
+	for _, g := range e.groups {
+		perms, ok := e.vaultGroups[vault.ID][g.ID]
+		if !ok {
+			continue
+		}
+		group := *g
+		group.Permissions = append([]string(nil), perms...)
+		rv = append(rv, group)
+	}
+
+	return rv, nil
+}
+
+func (e *Emulator) grantVaultUser(vaultRef, userRef string, permissions []string) error {
+	vault, err := e.findVault(vaultRef)
+	if err != nil {
+		return err
+	}
+	user, err := e.findUser(userRef)
+	if err != nil {
+		return err
+	}
+
+	return grantPermissions(e.vaultUsers, vault.ID, user.ID, permissions)
+}
+
+// revokeVaultUser fails with the CLI's "accessor doesn't have any permissions" error when the user
+// has no direct grant on the vault, which is the case when their access is inherited from a group.
+func (e *Emulator) revokeVaultUser(vaultRef, userRef string, permissions []string) error {
+	vault, err := e.findVault(vaultRef)
+	if err != nil {
+		return err
+	}
+	user, err := e.findUser(userRef)
+	if err != nil {
+		return err
+	}
+
+	return revokePermissions(e.vaultUsers, vault.ID, user.ID, permissions)
+}
+
+func (e *Emulator) grantVaultGroup(vaultRef, groupRef string, permissions []string) error {
+	vault, err := e.findVault(vaultRef)
+	if err != nil {
+		return err
+	}
+	group, err := e.findGroup(groupRef)
+	if err != nil {
+		return err
+	}
+
+	return grantPermissions(e.vaultGroups, vault.ID, group.ID, permissions)
+}
+
+func (e *Emulator) revokeVaultGroup(vaultRef, groupRef string, permissions []string) error {
+	vault, err := e.findVault(vaultRef)
+	if err != nil {
+		return err
+	}
+	group, err := e.findGroup(groupRef)
+	if err != nil {
+		return err
+	}
+
+	return revokePermissions(e.vaultGroups, vault.ID, group.ID, permissions)
+}
+
+// grantPermissions adds permissions to those of an accessor, a user or a group, on a vault.
+func grantPermissions(access map[string]map[string][]string, vaultID, accessorID string, permissions []string) error {
+	if len(permissions) == 0 {
+		return errorf("at least one permission must be specified")
+	}
+
+	if access[vaultID] == nil {
+		access[vaultID] = make(map[string][]string)
+	}
+
+	perms := append(access[vaultID][accessorID], permissions...)
+	slices.Sort(perms)
+	access[vaultID][accessorID] = slices.Compact(perms)
+
+	return nil
+}
+
+// revokePermissions removes permissions from those of an accessor on a vault, and the accessor once none are left.
+// Like the CLI, it refuses permissions that the accessor is not granted directly, such as those inherited from a group.
+func revokePermissions(access map[string]map[string][]string, vaultID, accessorID string, permissions []string) error {
+	direct, ok := access[vaultID][accessorID]
+	if !ok || len(direct) == 0 {
+		return errorf("the accessor doesn't have any permissions")
+	}
+	for _, p := range permissions {
+		if !slices.Contains(direct, p) {
+			return errorf("the accessor doesn't have the %s permission", p)
+		}
+	}
+
+	remaining := slices.DeleteFunc(slices.Clone(direct), func(p string) bool {
+		return slices.Contains(permissions, p)
+	})
+	if len(remaining) == 0 {
+		delete(access[vaultID], accessorID)
+		return nil
+	}
+	access[vaultID][accessorID] = remaining
+
+	return nil
+}
@@ -0,0 +1,122 @@
+// Package optest provides in-memory implementations of onepassword.CommandRunner
+// for tests that should not depend on a real `op` binary or 1Password account.
+package optest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+)
+
+// Response is a canned result for a scripted `op` invocation.
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Call records a single invocation of a runner.
+type Call struct {
+	Args  []string
+	Stdin []byte
+	Env   []string
+}
+
+type script struct {
+	args      []string
+	responses []Response
+}
+
+// ScriptedRunner replays canned responses for the commands it has been scripted with.
+// A script matches when its arguments are a prefix of the invoked arguments, the longest
+// matching script wins. Scripts with several responses return them in order and repeat the last one.
+type ScriptedRunner struct {
+	mu      sync.Mutex
+	scripts []*script
+	calls   []Call
+}
+
+func NewScriptedRunner() *ScriptedRunner {
+	return &ScriptedRunner{}
+}
+
+// On scripts the responses returned for commands starting with args.
+func (r *ScriptedRunner) On(args []string, responses ...Response) *ScriptedRunner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.scripts = append(r.scripts, &script{
+		args:      args,
+		responses: responses,
+	})
+
+	return r
+}
+
+// OnJSON scripts a successful response with stdout set to the given JSON document.
+func (r *ScriptedRunner) OnJSON(args []string, stdout string) *ScriptedRunner {
+	return r.On(args, Response{Stdout: stdout})
+}
+
+// Calls returns every invocation received so far.
+func (r *ScriptedRunner) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Call(nil), r.calls...)
+}
+
+func (r *ScriptedRunner) Run(ctx context.Context, args []string, stdin []byte, env []string) (*onepassword.CommandResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, Call{
+		Args:  append([]string(nil), args...),
+		Stdin: stdin,
+		Env:   append([]string(nil), env...),
+	})
+
+	var match *script
+	for _, s := range r.scripts {
+		if !hasPrefix(args, s.args) {
+			continue
+		}
+		if match == nil || len(s.args) > len(match.args) {
+			match = s
+		}
+	}
+
+	if match == nil || len(match.responses) == 0 {
+		return nil, fmt.Errorf("optest: unexpected op invocation: %s", strings.Join(args, " "))
+	}
+
+	res := match.responses[0]
+	if len(match.responses) > 1 {
+		match.responses = match.responses[1:]
+	}
+
+	return &onepassword.CommandResult{
+		Stdout:   []byte(res.Stdout),
+		Stderr:   []byte(res.Stderr),
+		ExitCode: res.ExitCode,
+	}, nil
+}
+
+func hasPrefix(args []string, prefix []string) bool {
+	if len(prefix) > len(args) {
+		return false
+	}
+	for i, p := range prefix {
+		if args[i] != p {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package onepassword
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+// DefaultConcurrency is the number of op commands run at once to prefetch vault and group access.
+const DefaultConcurrency = 4
+
+// WithConcurrency prefetches per vault and per group reads with up to n op commands at once.
+// Prefetched results land in the cache, where the regular List calls pick them up, so it has no effect without WithCache.
+// An n below 2 disables prefetching.
+func WithConcurrency(n int) Option {
+	return func(c *OnePasswordClient) {
+		if c.prefetcher != nil {
+			c.prefetcher.cancel()
+		}
+		if n < 2 {
+			c.prefetcher = nil
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		c.prefetcher = &prefetcher{
+			workers: semaphore.NewWeighted(int64(n)),
+			ctx:     ctx,
+			cancel:  cancel,
+		}
+	}
+}
+
+// prefetcher runs reads ahead of the sync, outliving the requests that schedule them.
+type prefetcher struct {
+	workers *semaphore.Weighted
+	// ctx ends when the client is closed.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// PrefetchVaultAccess starts reading the users and groups of the vaults in the background.
+func (c *OnePasswordClient) PrefetchVaultAccess(ctx context.Context, vaultIDs []string) {
+	jobs := make([]func(context.Context) error, 0, 2*len(vaultIDs))
+	for _, id := range vaultIDs {
+		jobs = append(jobs,
+			func(ctx context.Context) error {
+				_, err := c.ListVaultMembers(ctx, id)
+				return err
+			},
+			func(ctx context.Context) error {
+				_, err := c.ListVaultGroups(ctx, id)
+				return err
+			},
+		)
+	}
+	c.prefetch(ctx, jobs)
+}
+
+// PrefetchGroupMembers starts reading the members of the groups in the background.
+func (c *OnePasswordClient) PrefetchGroupMembers(ctx context.Context, groupIDs []string) {
+	// Group members come from the SCIM bridge when one is configured, its reads do not go through op or its cache.
+	if c.scim != nil {
+		return
+	}
+
+	jobs := make([]func(context.Context) error, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		jobs = append(jobs, func(ctx context.Context) error {
+			_, err := c.ListGroupMembers(ctx, id)
+			return err
+		})
+	}
+	c.prefetch(ctx, jobs)
+}
+
+// prefetch runs jobs on the worker pool and returns without waiting for them.
+// The jobs keep the values of ctx but not its deadline, since the request that schedules them ends first,
+// and they stop when the client is closed or once the rate budget is spent.
+func (c *OnePasswordClient) prefetch(ctx context.Context, jobs []func(context.Context) error) {
+	p := c.prefetcher
+	if p == nil || c.cache == nil || len(jobs) == 0 || p.ctx.Err() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(p.ctx, cancel)
+	l := ctxzap.Extract(ctx)
+
+	p.wg.Go(func() {
+		defer stop()
+		defer cancel()
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		for _, job := range jobs {
+			if err := p.workers.Acquire(ctx, 1); err != nil {
+				return
+			}
+			wg.Go(func() {
+				defer p.workers.Release(1)
+
+				err := job(ctx)
+				if err == nil || ctx.Err() != nil {
+					return
+				}
+				// Whatever failed is read again when the sync gets to it, and reports the error then.
+				l.Debug("op prefetch failed", zap.Error(err))
+				if errors.Is(err, ErrRateLimited) {
+					cancel()
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package onepassword_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/stretchr/testify/require"
+)
+
+// slowRunner delays every command and records how many ran at once.
+type slowRunner struct {
+	onepassword.CommandRunner
+	delay time.Duration
+
+	mu          sync.Mutex
+	running     int
+	maxRunning  int
+	interrupted int
+}
+
+func (r *slowRunner) Run(ctx context.Context, args []string, stdin []byte, env []string) (*onepassword.CommandResult, error) {
+	r.mu.Lock()
+	r.running++
+	r.maxRunning = max(r.maxRunning, r.running)
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.running--
+		r.mu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		r.mu.Lock()
+		r.interrupted++
+		r.mu.Unlock()
+		return nil, ctx.Err()
+	case <-time.After(r.delay):
+	}
+
+	return r.CommandRunner.Run(ctx, args, stdin, env)
+}
+
+func newPrefetchEmulator() *optest.Emulator {
+	emu := newTestEmulator()
+	for _, id := range []string{"V1", "V2", "V3", "V4"} {
+		emu.AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: id, Name: id}})
+		emu.SetVaultUserPermissions(id, "U1", "view_items")
+	}
+	return emu
+}
+
+func TestPrefetchFeedsReads(t *testing.T) {
+	emu := newPrefetchEmulator()
+	runner := &slowRunner{CommandRunner: emu, delay: 5 * time.Millisecond}
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithCache(time.Minute), onepassword.WithConcurrency(2))
+	defer cli.Close()
+
+	vaults := []string{"V1", "V2", "V3", "V4"}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cli.PrefetchVaultAccess(ctx, vaults)
+	// The prefetch outlives the request that started it.
+	cancel()
+
+	require.Eventually(t, func() bool {
+		return len(emu.Calls()) == 2*len(vaults)
+	}, time.Second, time.Millisecond)
+
+	for _, id := range vaults {
+		members, err := cli.ListVaultMembers(t.Context(), id)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		_, err = cli.ListVaultGroups(t.Context(), id)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, emu.Calls(), 2*len(vaults))
+	require.Equal(t, 2, runner.maxRunning)
+}
+
+func TestPrefetchStopsOnClose(t *testing.T) {
+	emu := newPrefetchEmulator()
+	runner := &slowRunner{CommandRunner: emu, delay: time.Hour}
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithCache(time.Minute), onepassword.WithConcurrency(2))
+
+	cli.PrefetchVaultAccess(t.Context(), []string{"V1", "V2", "V3", "V4"})
+	require.Eventually(t, func() bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		return runner.running == 2
+	}, time.Second, time.Millisecond)
+
+	cli.Close()
+
+	require.Eventually(t, func() bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		return runner.running == 0 && runner.interrupted == 2
+	}, time.Second, time.Millisecond)
+	require.Empty(t, emu.Calls())
+}
+
+func TestPrefetchDisabled(t *testing.T) {
+	emu := newPrefetchEmulator()
+
+	for _, opts := range [][]onepassword.Option{
+		{onepassword.WithCache(time.Minute)},
+		{onepassword.WithConcurrency(4)},
+		{onepassword.WithCache(time.Minute), onepassword.WithConcurrency(1)},
+	} {
+		cli := onepassword.NewCli("service", "", emu, opts...)
+		cli.PrefetchVaultAccess(t.Context(), []string{"V1", "V2"})
+		cli.Close()
+	}
+
+	require.Empty(t, emu.Calls())
+}
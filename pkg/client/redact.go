@@ -0,0 +1,113 @@
+package onepassword
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const redacted = "[REDACTED]"
+
+// Shorter values are not masked by value, every occurrence of them would be.
+const minRedactedLength = 4
+
+// Credentials and secret references that are masked even when the client does not know their value.
+var secretPatterns = []*regexp.Regexp{
+	// Service account tokens.
+	regexp.MustCompile(`ops_[A-Za-z0-9+/=_.\-]{16,}`),
+	// Secret keys, such as A3-ABCDEF-GHIJKL-MNOPQ-RSTUV-WXYZ1-23456.
+	regexp.MustCompile(`\bA3(?:-[A-Z0-9]{5,6}){5,6}\b`),
+	// Secret references, which resolve to item fields.
+	regexp.MustCompile(`op://[^\s"']+`),
+}
+
+// redactor masks credentials before they are logged.
+type redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// add registers values to mask, such as a session token once it is issued.
+func (r *redactor) add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if len(secret) >= minRedactedLength {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+}
+
+// redact masks the registered secrets and anything matching secretPatterns in s.
+func (r *redactor) redact(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	r.mu.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+
+	return s
+}
+
+func (r *redactor) redactAll(values []string) []string {
+	rv := make([]string, len(values))
+	for i, v := range values {
+		rv[i] = r.redact(v)
+	}
+	return rv
+}
+
+// envNames returns the names of env entries, their values are credentials.
+func envNames(env []string) []string {
+	names := make([]string, len(env))
+	for i, kv := range env {
+		names[i], _, _ = strings.Cut(kv, "=")
+	}
+	return names
+}
+
+// run executes op through the runner of the client.
+// The stderr of the result is redacted, so the errors built from it can be logged, and the command is
+// traced at debug level. The trace leaves out stdout and credentials, it is safe to attach to support tickets.
+func (c *OnePasswordClient) run(ctx context.Context, args []string, stdin []byte, env []string) (*CommandResult, error) {
+	start := time.Now()
+	res, err := c.runner.Run(ctx, args, stdin, env)
+
+	fields := []zap.Field{
+		zap.Strings("args", c.redactor.redactAll(args)),
+		zap.Strings("env", envNames(env)),
+		zap.Bool("stdin", stdin != nil),
+		zap.String("phase", budgetPhase(ctx)),
+		zap.Duration("duration", time.Since(start)),
+	}
+
+	l := ctxzap.Extract(ctx)
+	if err != nil {
+		l.Debug("op command", append(fields, zap.String("error", c.redactor.redact(err.Error())))...)
+		return nil, err
+	}
+
+	res.Stderr = []byte(c.redactor.redact(string(res.Stderr)))
+
+	l.Debug("op command", append(fields,
+		zap.Int("exit_code", res.ExitCode),
+		zap.Int("stdout_bytes", len(res.Stdout)),
+		zap.String("stderr", string(res.Stderr)),
+	)...)
+
+	return res, nil
+}
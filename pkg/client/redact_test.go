@@ -0,0 +1,100 @@
+package onepassword_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	testServiceToken = "ops_eyJzaWduSW5BZGRyZXNzIjoiZXhhbXBsZS4xcGFzc3dvcmQuY29tIn0"
+	testSecretKey    = "A3-ABC123-DEF456-GHI78-JKL90-MNO12-PQR34"
+)
+
+// logsTo returns a context whose zap logger writes every level to buf.
+func logsTo(ctx context.Context, buf *bytes.Buffer) context.Context {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(buf), zapcore.DebugLevel)
+	return ctxzap.ToContext(ctx, zap.New(core))
+}
+
+func TestLogsAreRedacted(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		secret string
+	}{
+		{
+			name:   "service account token",
+			stderr: "[ERROR] 2024/05/01 12:00:00 invalid token " + testServiceToken,
+			secret: testServiceToken,
+		},
+		{
+			name:   "unknown service account token",
+			stderr: "[ERROR] 2024/05/01 12:00:00 invalid token ops_c29tZSBvdGhlciB0b2tlbiB2YWx1ZQ",
+			secret: "ops_c29tZSBvdGhlciB0b2tlbiB2YWx1ZQ",
+		},
+		{
+			name:   "secret key",
+			stderr: "[ERROR] 2024/05/01 12:00:00 secret key " + testSecretKey + " is invalid",
+			secret: testSecretKey,
+		},
+		{
+			name:   "secret reference",
+			stderr: `[ERROR] 2024/05/01 12:00:00 could not read "op://Shared/Database/password"`,
+			secret: "op://Shared/Database/password",
+		},
+		{
+			name:   "password",
+			stderr: "[ERROR] 2024/05/01 12:00:00 authentication failed for password " + testPassword,
+			secret: testPassword,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			ctx := logsTo(t.Context(), &logs)
+
+			runner := optest.NewScriptedRunner().On([]string{"user", "list"}, optest.Response{Stderr: tt.stderr, ExitCode: 1})
+			details := onepassword.NewAccount("example.1password.com", "alice@example.com", testSecretKey, testPassword)
+			cli := onepassword.NewCli("service", testServiceToken, runner, onepassword.WithAccountDetails(details))
+
+			_, err := cli.ListUsers(ctx)
+			require.Error(t, err)
+			require.NotContains(t, err.Error(), tt.secret)
+			require.Contains(t, err.Error(), "[REDACTED]")
+
+			require.Contains(t, logs.String(), `"msg":"op command"`)
+			require.Contains(t, logs.String(), `"msg":"error executing command"`)
+			require.NotContains(t, logs.String(), tt.secret)
+		})
+	}
+}
+
+func TestCommandTrace(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := logsTo(onepassword.WithBudgetPhase(t.Context(), "users"), &logs)
+
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", testServiceToken, emu, onepassword.WithConfigDir(t.TempDir()))
+
+	_, err := cli.ListUsers(ctx)
+	require.NoError(t, err)
+
+	trace := logs.String()
+	require.Contains(t, trace, `"level":"debug"`)
+	require.Contains(t, trace, `"msg":"op command"`)
+	require.Contains(t, trace, `"args":["user","list","--format=json"]`)
+	require.Contains(t, trace, `"env":["OP_CONFIG_DIR","OP_SERVICE_ACCOUNT_TOKEN"]`)
+	require.Contains(t, trace, `"phase":"users"`)
+	require.Contains(t, trace, `"exit_code":0`)
+	require.NotContains(t, trace, testServiceToken)
+	require.NotContains(t, trace, "alice@example.com")
+}
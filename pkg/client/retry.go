@@ -0,0 +1,155 @@
+package onepassword
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// RetryPolicy controls how failed op invocations are retried.
+// Only reads are retried automatically, and only when they failed for a transient reason.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// WithRetryPolicy overrides DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *OnePasswordClient) {
+		c.retry = policy
+	}
+}
+
+// RateLimitError is returned when op is still rate limited after the retries allowed by the RetryPolicy.
+type RateLimitError struct {
+	// ResetAt is the earliest time the request should be tried again.
+	ResetAt time.Time
+	Err     error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exhausted until %s: %s", e.ResetAt.Format(time.RFC3339), e.Err)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Subcommand verbs that only read state and can be repeated safely.
+var readVerbs = []string{"list", "get", "whoami"}
+
+// nestedCommands are the commands whose verb follows a second noun, such as `op group user list`.
+var nestedCommands = map[string][]string{
+	"group": {"user"},
+	"vault": {"user", "group"},
+}
+
+// isReadCommand reports whether the verb of a command only reads state.
+// Only the verb position counts, so that `op group create list` is a write even though it names a group list.
+func isReadCommand(args []string) bool {
+	var positional []string
+	for _, arg := range args {
+		if len(arg) > 0 && arg[0] == '-' {
+			break
+		}
+		positional = append(positional, arg)
+	}
+
+	var verb string
+	switch {
+	case len(positional) == 1:
+		verb = positional[0]
+	case len(positional) > 2 && slices.Contains(nestedCommands[positional[0]], positional[1]):
+		verb = positional[2]
+	case len(positional) > 1:
+		verb = positional[1]
+	}
+
+	return slices.Contains(readVerbs, verb)
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable)
+}
+
+// backoff returns the delay before the given retry, growing exponentially with full jitter
+// over its upper half so concurrent callers do not retry in lockstep.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.MaxDelay
+	if retry < 32 {
+		delay = min(p.BaseDelay<<retry, p.MaxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// executeWithRetry runs a command, retrying transient failures of reads with exponential backoff.
+func (c *OnePasswordClient) executeWithRetry(ctx context.Context, args []string) (*CommandResult, error) {
+	l := ctxzap.Extract(ctx)
+
+	attempts := 1
+	if isReadCommand(args) {
+		attempts = max(c.retry.MaxAttempts, 1)
+	}
+
+	var cmdErr *CommandError
+	for attempt := range attempts {
+		if err := c.waitForBudget(ctx, args); err != nil {
+			return nil, err
+		}
+
+		out, err := c.executeOnce(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		if out.ExitCode == 0 {
+			return out, nil
+		}
+
+		cmdErr = newCommandError(out)
+		if !isRetryable(cmdErr) || attempt == attempts-1 {
+			break
+		}
+
+		wait := c.retry.backoff(attempt)
+		l.Warn("retrying op command",
+			zap.Strings("command", c.redactor.redactAll(args[:min(len(args), 3)])),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("wait", wait),
+			zap.Error(cmdErr),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if errors.Is(cmdErr, ErrRateLimited) {
+		return nil, &RateLimitError{
+			ResetAt: c.rateLimitResetAt(args),
+			Err:     cmdErr,
+		}
+	}
+
+	return nil, cmdErr
+}
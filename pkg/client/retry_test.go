@@ -0,0 +1,109 @@
+package onepassword_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = onepassword.RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+}
+
+var (
+	rateLimited = optest.Response{Stderr: "[ERROR] 2024/05/01 12:00:00 Too many requests (429)", ExitCode: 1}
+	unavailable = optest.Response{Stderr: "[ERROR] 2024/05/01 12:00:00 Service Unavailable (503)", ExitCode: 1}
+	notFound    = optest.Response{Stderr: `[ERROR] 2024/05/01 12:00:00 "G1" isn't a group in this account.`, ExitCode: 1}
+)
+
+func TestRetryReads(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []optest.Response
+		calls     int
+		err       error
+	}{
+		{
+			name:      "recovers from rate limit",
+			responses: []optest.Response{rateLimited, rateLimited, {Stdout: `[]`}},
+			calls:     3,
+		},
+		{
+			name:      "recovers from unavailable",
+			responses: []optest.Response{unavailable, {Stdout: `[]`}},
+			calls:     2,
+		},
+		{
+			name:      "rate limit exhausted",
+			responses: []optest.Response{rateLimited},
+			calls:     3,
+			err:       onepassword.ErrRateLimited,
+		},
+		{
+			name:      "permanent failure",
+			responses: []optest.Response{notFound},
+			calls:     1,
+			err:       onepassword.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := optest.NewScriptedRunner().On([]string{"group", "list"}, tt.responses...)
+			cli := onepassword.NewCli("service", "", runner, onepassword.WithRetryPolicy(testRetryPolicy))
+
+			_, err := cli.ListGroups(t.Context())
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, runner.Calls(), tt.calls)
+		})
+	}
+}
+
+func TestRateLimitedWriteIsNotRetried(t *testing.T) {
+	runner := optest.NewScriptedRunner().On([]string{"group", "user", "grant"}, rateLimited, optest.Response{})
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithRetryPolicy(testRetryPolicy))
+
+	err := cli.AddUserToGroup(t.Context(), "G1", "member", "U1")
+	require.ErrorIs(t, err, onepassword.ErrRateLimited)
+	require.Len(t, runner.Calls(), 1)
+
+	var rlErr *onepassword.RateLimitError
+	require.True(t, errors.As(err, &rlErr))
+	require.True(t, rlErr.ResetAt.After(time.Now()))
+}
+
+func TestWriteNamedLikeAReadIsNotRetried(t *testing.T) {
+	runner := optest.NewScriptedRunner().On([]string{"group", "create", "list"}, rateLimited, optest.Response{})
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithRetryPolicy(testRetryPolicy))
+
+	_, err := cli.CreateGroup(t.Context(), "list", "")
+	require.ErrorIs(t, err, onepassword.ErrRateLimited)
+	require.Len(t, runner.Calls(), 1)
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	runner := optest.NewScriptedRunner().On([]string{"group", "list"}, rateLimited)
+	cli := onepassword.NewCli("service", "", runner, onepassword.WithRetryPolicy(onepassword.RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Hour,
+		MaxDelay:    time.Hour,
+	}))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := cli.ListGroups(ctx)
+	require.ErrorIs(t, err, ctx.Err())
+	require.Len(t, runner.Calls(), 1)
+}
@@ -0,0 +1,178 @@
+package onepassword
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const defaultBinary = "op"
+
+// CommandResult holds the outcome of a single 1Password CLI invocation.
+type CommandResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// CommandRunner executes the 1Password CLI.
+// Implementations only return an error when the command could not be run at all,
+// a command that ran and failed is reported through CommandResult.ExitCode.
+type CommandRunner interface {
+	Run(ctx context.Context, args []string, stdin []byte, env []string) (*CommandResult, error)
+}
+
+type execRunner struct {
+	binary string
+	// digests are the allowed SHA-256 digests of the binary, hex encoded. Any binary is allowed if empty.
+	digests []string
+
+	// verifyOnce resolves and verifies the binary before the first command, path and verifyErr hold the outcome.
+	verifyOnce sync.Once
+	path       string
+	verifyErr  error
+}
+
+type ExecRunnerOption func(r *execRunner)
+
+// WithBinary runs the op executable at path instead of the one found in $PATH.
+// A path without a separator is still looked up in $PATH.
+func WithBinary(path string) ExecRunnerOption {
+	return func(r *execRunner) {
+		if path != "" {
+			r.binary = path
+		}
+	}
+}
+
+// WithBinaryDigests only runs an op executable whose SHA-256 digest, hex encoded, is one of digests.
+func WithBinaryDigests(digests ...string) ExecRunnerOption {
+	return func(r *execRunner) {
+		for _, digest := range digests {
+			if digest = strings.ToLower(strings.TrimSpace(digest)); digest != "" {
+				r.digests = append(r.digests, digest)
+			}
+		}
+	}
+}
+
+// NewExecRunner returns a CommandRunner that executes the `op` binary found in $PATH.
+func NewExecRunner(opts ...ExecRunnerOption) CommandRunner {
+	r := &execRunner{
+		binary: defaultBinary,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// verify resolves the binary to an absolute path and checks its digest, once.
+// Commands run the resolved path, so a later change of $PATH cannot swap the binary.
+func (r *execRunner) verify() (string, error) {
+	r.verifyOnce.Do(func() {
+		path, err := exec.LookPath(r.binary)
+		if err != nil {
+			r.verifyErr = fmt.Errorf("error finding op executable: %w", err)
+			return
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			r.verifyErr = fmt.Errorf("error finding op executable: %w", err)
+			return
+		}
+
+		if len(r.digests) > 0 {
+			digest, err := fileDigest(path)
+			if err != nil {
+				r.verifyErr = fmt.Errorf("error verifying op executable: %w", err)
+				return
+			}
+			if !slices.Contains(r.digests, digest) {
+				r.verifyErr = fmt.Errorf("refusing to run op executable %s: its SHA-256 digest %s is not one of the allowed digests %s: %w",
+					path, digest, strings.Join(r.digests, ", "), ErrBinaryMismatch)
+				return
+			}
+		}
+
+		r.path = path
+	})
+
+	return r.path, r.verifyErr
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Run executes the binary with the given arguments.
+// The provided env entries are appended to the environment of the current process, less its OP_ variables.
+func (r *execRunner) Run(ctx context.Context, args []string, stdin []byte, env []string) (*CommandResult, error) {
+	var (
+		stdout bytes.Buffer
+		stderr bytes.Buffer
+	)
+
+	path, err := r.verify()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	cmd.Env = append(inheritedEnv(), env...)
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	res := &CommandResult{
+		Stdout: stdout.Bytes(),
+		Stderr: stderr.Bytes(),
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		res.ExitCode = exitErr.ExitCode()
+	}
+
+	return res, nil
+}
+
+// inheritedEnv returns the environment of the current process without its OP_ variables, such as OP_SERVICE_ACCOUNT_TOKEN,
+// OP_SESSION_* or OP_CONFIG_DIR, so that op only runs with the credentials and configuration the client passes to each command.
+func inheritedEnv() []string {
+	return slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		return strings.HasPrefix(kv, "OP_")
+	})
+}
@@ -0,0 +1,82 @@
+package onepassword
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOp writes an executable script standing in for op, and returns its path and SHA-256 digest.
+func fakeOp(t *testing.T) (string, string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake op is a shell script")
+	}
+
+	script := []byte("#!/bin/sh\necho 2.30.0\n")
+	path := filepath.Join(t.TempDir(), "op")
+	require.NoError(t, os.WriteFile(path, script, 0o700))
+
+	sum := sha256.Sum256(script)
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestExecRunnerVerifiesBinary(t *testing.T) {
+	path, digest := fakeOp(t)
+
+	tests := []struct {
+		name    string
+		digests []string
+		err     error
+	}{
+		{name: "no digest"},
+		{name: "allowed digest", digests: []string{"0000000000000000000000000000000000000000000000000000000000000000", digest}},
+		{name: "digest case and spaces", digests: []string{" " + strings.ToUpper(digest) + " "}},
+		{name: "mismatch", digests: []string{"0000000000000000000000000000000000000000000000000000000000000000"}, err: ErrBinaryMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewExecRunner(WithBinary(path), WithBinaryDigests(tt.digests...))
+
+			res, err := runner.Run(t.Context(), []string{"--version"}, nil, nil)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				require.ErrorContains(t, err, path)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "2.30.0\n", string(res.Stdout))
+		})
+	}
+}
+
+func TestExecRunnerDropsInheritedOpEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake op is a shell script")
+	}
+
+	path := filepath.Join(t.TempDir(), "op")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nenv | grep '^OP_' | sort\n"), 0o700))
+
+	t.Setenv("OP_SERVICE_ACCOUNT_TOKEN", "ops_process")
+	t.Setenv("OP_SESSION_U1", "process-session")
+	t.Setenv("OP_CONFIG_DIR", "/home/alice/.config/op")
+
+	runner := NewExecRunner(WithBinary(path))
+
+	res, err := runner.Run(t.Context(), []string{"whoami"}, nil, []string{"OP_CONFIG_DIR=/tmp/op", "OP_SESSION_U2=command-session"})
+	require.NoError(t, err)
+	require.Equal(t, "OP_CONFIG_DIR=/tmp/op\nOP_SESSION_U2=command-session\n", string(res.Stdout))
+
+	res, err = runner.Run(t.Context(), []string{"--version"}, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, string(res.Stdout))
+}
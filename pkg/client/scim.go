@@ -0,0 +1,323 @@
+package onepassword
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+)
+
+const (
+	scimPageSize      = 100
+	scimPatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+)
+
+// SCIMClient talks SCIM 2.0 to a 1Password SCIM bridge.
+// It covers the user and group operations of OnePasswordClient for deployments without the op CLI.
+type SCIMClient struct {
+	baseURL *url.URL
+	token   string
+	client  *uhttp.BaseHttpClient
+}
+
+type scimError struct {
+	Detail string `json:"detail"`
+	Status string `json:"status"`
+}
+
+func (e *scimError) Message() string {
+	return e.Detail
+}
+
+type scimListResponse[T any] struct {
+	TotalResults int `json:"totalResults"`
+	ItemsPerPage int `json:"itemsPerPage"`
+	StartIndex   int `json:"startIndex"`
+	Resources    []T `json:"Resources"`
+}
+
+type scimUser struct {
+	ID       string `json:"id"`
+	UserName string `json:"userName"`
+	Name     struct {
+		Formatted  string `json:"formatted"`
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+	} `json:"name"`
+	DisplayName string `json:"displayName"`
+	Emails      []struct {
+		Value   string `json:"value"`
+		Primary bool   `json:"primary"`
+	} `json:"emails"`
+	Active bool `json:"active"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimGroup struct {
+	ID          string       `json:"id"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members,omitempty"`
+}
+
+type scimPatchOperation struct {
+	Op    string       `json:"op"`
+	Path  string       `json:"path"`
+	Value []scimMember `json:"value,omitempty"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+// NewSCIMClient returns a client for the SCIM bridge at bridgeURL authenticated with the bridge bearer token.
+func NewSCIMClient(ctx context.Context, bridgeURL string, token string) (*SCIMClient, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(bridgeURL, "/") + "/scim/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid SCIM bridge url: %w", err)
+	}
+
+	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+
+	client, err := uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+
+	return &SCIMClient{
+		baseURL: baseURL,
+		token:   token,
+		client:  client,
+	}, nil
+}
+
+// Account returns the account served by the SCIM bridge.
+// SCIM has no account endpoint, so the account is identified by the bridge host.
+func (s *SCIMClient) Account() Account {
+	return Account{
+		BaseType: BaseType{
+			ID:   s.baseURL.Host,
+			Name: s.baseURL.Host,
+		},
+		Domain: s.baseURL.Host,
+		State:  "ACTIVE",
+	}
+}
+
+// Validate checks that the bridge is reachable and the bearer token is accepted.
+func (s *SCIMClient) Validate(ctx context.Context) error {
+	var res scimListResponse[scimUser]
+	if err := s.do(ctx, http.MethodGet, "Users", url.Values{"count": {"1"}}, nil, &res); err != nil {
+		return fmt.Errorf("error validating SCIM bridge: %w", err)
+	}
+
+	return nil
+}
+
+// ListUsers lists all users provisioned through the SCIM bridge.
+func (s *SCIMClient) ListUsers(ctx context.Context) ([]User, error) {
+	users, err := listAll[scimUser](ctx, s, "Users")
+	if err != nil {
+		return nil, fmt.Errorf("error listing users: %w", err)
+	}
+
+	rv := make([]User, 0, len(users))
+	for _, u := range users {
+		rv = append(rv, u.toUser())
+	}
+
+	return rv, nil
+}
+
+// ListGroups lists all groups known to the SCIM bridge.
+func (s *SCIMClient) ListGroups(ctx context.Context) ([]Group, error) {
+	groups, err := listAll[scimGroup](ctx, s, "Groups")
+	if err != nil {
+		return nil, fmt.Errorf("error listing groups: %w", err)
+	}
+
+	rv := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		rv = append(rv, Group{
+			BaseType: BaseType{
+				ID:   g.ID,
+				Name: g.DisplayName,
+			},
+			State: "ACTIVE",
+		})
+	}
+
+	return rv, nil
+}
+
+// ListGroupMembers lists all members of a group.
+// SCIM does not expose group roles, so every member is reported with the member role.
+func (s *SCIMClient) ListGroupMembers(ctx context.Context, group string) ([]User, error) {
+	var res scimGroup
+	if err := s.do(ctx, http.MethodGet, "Groups/"+url.PathEscape(group), nil, nil, &res); err != nil {
+		return nil, fmt.Errorf("error listing group members: %w", err)
+	}
+
+	rv := make([]User, 0, len(res.Members))
+	for _, m := range res.Members {
+		rv = append(rv, User{
+			BaseType: BaseType{
+				ID:   m.Value,
+				Name: m.Display,
+			},
+			Role: "MEMBER",
+		})
+	}
+
+	return rv, nil
+}
+
+// AddUserToGroup adds user to group.
+// Only the member role can be granted, SCIM has no notion of group managers.
+func (s *SCIMClient) AddUserToGroup(ctx context.Context, group, role, user string) error {
+	if role != "member" {
+		return fmt.Errorf("error adding user to group: the SCIM bridge cannot grant the %s role", role)
+	}
+
+	req := scimPatchRequest{
+		Schemas: []string{scimPatchOpSchema},
+		Operations: []scimPatchOperation{
+			{
+				Op:    "add",
+				Path:  "members",
+				Value: []scimMember{{Value: user}},
+			},
+		},
+	}
+
+	if err := s.do(ctx, http.MethodPatch, "Groups/"+url.PathEscape(group), nil, req, nil); err != nil {
+		return fmt.Errorf("error adding user as a member: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveUserFromGroup removes user from group.
+func (s *SCIMClient) RemoveUserFromGroup(ctx context.Context, group, user string) error {
+	req := scimPatchRequest{
+		Schemas: []string{scimPatchOpSchema},
+		Operations: []scimPatchOperation{
+			{
+				Op:   "remove",
+				Path: fmt.Sprintf("members[value eq %q]", user),
+			},
+		},
+	}
+
+	if err := s.do(ctx, http.MethodPatch, "Groups/"+url.PathEscape(group), nil, req, nil); err != nil {
+		return fmt.Errorf("error removing user from group: %w", err)
+	}
+
+	return nil
+}
+
+func (u scimUser) toUser() User {
+	name := u.DisplayName
+	if name == "" {
+		name = u.Name.Formatted
+	}
+	if name == "" {
+		name = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+
+	email := u.UserName
+	for _, e := range u.Emails {
+		if e.Primary {
+			email = e.Value
+			break
+		}
+	}
+
+	state := "ACTIVE"
+	if !u.Active {
+		state = "SUSPENDED"
+	}
+
+	return User{
+		BaseType: BaseType{
+			ID:   u.ID,
+			Name: name,
+		},
+		Email: email,
+		Type:  "MEMBER",
+		State: state,
+	}
+}
+
+// listAll follows SCIM index based pagination until every resource has been returned.
+func listAll[T any](ctx context.Context, s *SCIMClient, path string) ([]T, error) {
+	var rv []T
+
+	startIndex := 1
+	for {
+		query := url.Values{
+			"startIndex": {strconv.Itoa(startIndex)},
+			"count":      {strconv.Itoa(scimPageSize)},
+		}
+
+		var res scimListResponse[T]
+		if err := s.do(ctx, http.MethodGet, path, query, nil, &res); err != nil {
+			return nil, err
+		}
+
+		rv = append(rv, res.Resources...)
+
+		if len(res.Resources) == 0 || len(rv) >= res.TotalResults {
+			return rv, nil
+		}
+		startIndex += len(res.Resources)
+	}
+}
+
+func (s *SCIMClient) do(ctx context.Context, method string, path string, query url.Values, body any, res any) error {
+	u := s.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	opts := []uhttp.RequestOption{
+		uhttp.WithBearerToken(s.token),
+		uhttp.WithAccept("application/scim+json"),
+		uhttp.WithNoCache(),
+	}
+	if body != nil {
+		opts = append(opts, uhttp.WithJSONBody(body), uhttp.WithContentType("application/scim+json"))
+	}
+
+	req, err := s.client.NewRequest(ctx, method, u, opts...)
+	if err != nil {
+		return err
+	}
+
+	doOpts := []uhttp.DoOption{
+		uhttp.WithErrorResponse(&scimError{}),
+	}
+	if res != nil {
+		doOpts = append(doOpts, uhttp.WithJSONResponse(res))
+	}
+
+	resp, err := s.client.Do(req, doOpts...)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
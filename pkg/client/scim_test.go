@@ -0,0 +1,160 @@
+package onepassword
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSCIMToken = "scim-token"
+
+// scimStandIn is a minimal in-memory SCIM bridge serving the endpoints used by SCIMClient.
+type scimStandIn struct {
+	mu      sync.Mutex
+	users   []scimUser
+	groups  []scimGroup
+	patches int
+}
+
+var removeMemberPath = regexp.MustCompile(`^members\[value eq "(.+)"\]$`)
+
+func (s *scimStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+testSCIMToken {
+		writeSCIM(w, http.StatusUnauthorized, scimError{Detail: "invalid bearer token", Status: "401"})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/scim/")
+	switch {
+	case r.Method == http.MethodGet && path == "Users":
+		writeSCIM(w, http.StatusOK, page(s.users, r))
+	case r.Method == http.MethodGet && path == "Groups":
+		writeSCIM(w, http.StatusOK, page(s.groups, r))
+	case strings.HasPrefix(path, "Groups/"):
+		idx := slices.IndexFunc(s.groups, func(g scimGroup) bool { return g.ID == strings.TrimPrefix(path, "Groups/") })
+		if idx < 0 {
+			writeSCIM(w, http.StatusNotFound, scimError{Detail: "group not found", Status: "404"})
+			return
+		}
+		if r.Method == http.MethodPatch {
+			s.patch(&s.groups[idx], r)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeSCIM(w, http.StatusOK, s.groups[idx])
+	default:
+		writeSCIM(w, http.StatusNotFound, scimError{Detail: "not found", Status: "404"})
+	}
+}
+
+func (s *scimStandIn) patch(group *scimGroup, r *http.Request) {
+	s.patches++
+
+	var req scimPatchRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	for _, op := range req.Operations {
+		switch op.Op {
+		case "add":
+			group.Members = append(group.Members, op.Value...)
+		case "remove":
+			m := removeMemberPath.FindStringSubmatch(op.Path)
+			if m == nil {
+				continue
+			}
+			group.Members = slices.DeleteFunc(group.Members, func(member scimMember) bool { return member.Value == m[1] })
+		}
+	}
+}
+
+func page[T any](items []T, r *http.Request) scimListResponse[T] {
+	start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	start = max(start, 1)
+	end := min(start-1+count, len(items))
+
+	res := scimListResponse[T]{TotalResults: len(items), StartIndex: start}
+	if start-1 < end {
+		res.Resources = items[start-1 : end]
+	}
+	res.ItemsPerPage = len(res.Resources)
+	return res
+}
+
+func writeSCIM(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func newTestSCIMClient(t *testing.T, standIn *scimStandIn, token string) *SCIMClient {
+	t.Helper()
+
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	client, err := NewSCIMClient(t.Context(), server.URL, token)
+	require.NoError(t, err)
+	return client
+}
+
+func TestSCIMListUsers(t *testing.T) {
+	standIn := &scimStandIn{}
+	for i := range 150 {
+		u := scimUser{ID: fmt.Sprintf("U%d", i), UserName: fmt.Sprintf("user%d@example.com", i), DisplayName: fmt.Sprintf("User %d", i), Active: i%2 == 0}
+		standIn.users = append(standIn.users, u)
+	}
+
+	users, err := newTestSCIMClient(t, standIn, testSCIMToken).ListUsers(t.Context())
+	require.NoError(t, err)
+	require.Len(t, users, 150)
+	require.Equal(t, User{BaseType: BaseType{ID: "U0", Name: "User 0"}, Email: "user0@example.com", Type: "MEMBER", State: "ACTIVE"}, users[0])
+	require.Equal(t, "SUSPENDED", users[1].State)
+}
+
+func TestSCIMGroupMembershipRoundTrip(t *testing.T) {
+	standIn := &scimStandIn{
+		users:  []scimUser{{ID: "U1", UserName: "alice@example.com", DisplayName: "Alice", Active: true}},
+		groups: []scimGroup{{ID: "G1", DisplayName: "Engineering"}},
+	}
+	cli := NewCli(AuthTypeSCIM, "", nil, WithSCIMBridge(newTestSCIMClient(t, standIn, testSCIMToken)))
+	require.False(t, cli.HasCLI())
+
+	groups, err := cli.ListGroups(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []Group{{BaseType: BaseType{ID: "G1", Name: "Engineering"}, State: "ACTIVE"}}, groups)
+
+	require.NoError(t, cli.AddUserToGroup(t.Context(), "G1", "member", "U1"))
+
+	members, err := cli.ListGroupMembers(t.Context(), "G1")
+	require.NoError(t, err)
+	require.Equal(t, []User{{BaseType: BaseType{ID: "U1"}, Role: "MEMBER"}}, members)
+
+	require.NoError(t, cli.RemoveUserFromGroup(t.Context(), "G1", "U1"))
+
+	members, err = cli.ListGroupMembers(t.Context(), "G1")
+	require.NoError(t, err)
+	require.Empty(t, members)
+
+	require.Error(t, cli.AddUserToGroup(t.Context(), "G1", "manager", "U1"))
+	require.Equal(t, 2, standIn.patches)
+}
+
+func TestSCIMUnauthorized(t *testing.T) {
+	client := newTestSCIMClient(t, &scimStandIn{}, "wrong-token")
+
+	err := client.Validate(t.Context())
+	require.ErrorContains(t, err, "invalid bearer token")
+}
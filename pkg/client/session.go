@@ -0,0 +1,96 @@
+package onepassword
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+func isSessionExpired(stderr []byte) bool {
+	return errors.Is(classifyError(string(stderr)), ErrSessionExpired)
+}
+
+func (c *OnePasswordClient) sessionToken() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+
+	return c.token
+}
+
+func (c *OnePasswordClient) sessionUserID() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+
+	return c.sessionUser
+}
+
+// canSignIn reports whether an expired session can be renewed.
+// Only user-mode clients have a session, service account tokens do not expire.
+func (c *OnePasswordClient) canSignIn() bool {
+	return c.authType == "user" && c.accountDetails != nil
+}
+
+// refreshSession signs in again if staleToken is still the current session token.
+// Callers that saw the same expired token wait on the lock and reuse the session created by the first one.
+func (c *OnePasswordClient) refreshSession(ctx context.Context, staleToken string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.token != staleToken {
+		return nil
+	}
+
+	ctxzap.Extract(ctx).Info("op session expired, signing in again")
+
+	return c.signIn(ctx)
+}
+
+// SignInUser starts the session of a user-mode client, adding the account to its op configuration first if needed.
+func (c *OnePasswordClient) SignInUser(ctx context.Context) error {
+	if c.accountDetails == nil {
+		return fmt.Errorf("account details are required for user auth-type")
+	}
+
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	return c.signIn(ctx)
+}
+
+// signIn must be called with sessionMu held.
+func (c *OnePasswordClient) signIn(ctx context.Context) error {
+	l := ctxzap.Extract(ctx)
+
+	if c.accountDetails.password == "" {
+		l.Error("password must be provided")
+		return fmt.Errorf("password is required for user auth-type")
+	}
+
+	account, err := c.findLocalAccount(ctx, c.accountDetails.address, c.accountDetails.email)
+	if err != nil {
+		l.Error("failed to check local accounts: ", zap.Error(err))
+		return err
+	}
+
+	if account == nil {
+		if account, err = c.addLocalAccount(ctx, c.accountDetails); err != nil {
+			l.Error("failed to add local account: ", zap.Error(err))
+			return err
+		}
+	}
+
+	token, err := c.signInAccount(ctx, account.AccountUUID, c.accountDetails)
+	if err != nil {
+		l.Error("failed to SignIn: ", zap.Error(err))
+		return err
+	}
+
+	c.redactor.add(token)
+	c.token = token
+	c.sessionUser = account.UserUUID
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package onepassword_test
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/stretchr/testify/require"
+)
+
+const testPassword = "correct horse battery staple"
+
+func newSignedInClient(t *testing.T) (*onepassword.OnePasswordClient, *optest.Emulator) {
+	t.Helper()
+
+	emu := newTestEmulator().RequireSignIn(testPassword)
+
+	details := onepassword.NewAccount("example.1password.com", "alice@example.com", "A3-SECRET", testPassword)
+	cli := onepassword.NewCli("user", "", emu, onepassword.WithAccountDetails(details), onepassword.WithConfigDir(t.TempDir()))
+	require.NoError(t, cli.SignInUser(t.Context()))
+	require.Equal(t, 1, emu.SignIns())
+
+	return cli, emu
+}
+
+func TestSignInKeepsCredentialsOutOfProcess(t *testing.T) {
+	emu := newTestEmulator().RequireSignIn(testPassword)
+
+	dir, err := onepassword.NewConfigDir()
+	require.NoError(t, err)
+	details := onepassword.NewAccount("example.1password.com", "alice@example.com", "A3-SECRET", testPassword)
+	cli := onepassword.NewCli("user", "", emu, onepassword.WithAccountDetails(details), onepassword.WithConfigDir(dir))
+
+	require.NoError(t, cli.SignInUser(t.Context()))
+	_, err = cli.ListUsers(t.Context())
+	require.NoError(t, err)
+
+	calls := emu.Calls()
+	require.Equal(t, []string{"accounts", "list", "account", "add", "accounts", "list", "signin", "user", "list"}, commandWords(calls))
+	for _, call := range calls {
+		require.Contains(t, call.Env, "OP_CONFIG_DIR="+dir)
+		require.NotContains(t, call.Args, "session-1")
+		if call.Args[0] == "account" {
+			require.Contains(t, call.Env, "OP_SECRET_KEY=A3-SECRET")
+		} else {
+			require.NotContains(t, call.Env, "OP_SECRET_KEY=A3-SECRET")
+		}
+	}
+	require.Contains(t, calls[len(calls)-1].Env, "OP_SESSION_U1=session-1")
+	require.Empty(t, os.Getenv("OP_SECRET_KEY"))
+
+	require.DirExists(t, dir)
+	require.NoError(t, cli.Close())
+	require.NoDirExists(t, dir)
+}
+
+// commandWords returns the subcommands of the calls, without their flags.
+func commandWords(calls []optest.Call) []string {
+	var words []string
+	for _, call := range calls {
+		for _, arg := range call.Args {
+			if strings.HasPrefix(arg, "-") {
+				break
+			}
+			words = append(words, arg)
+		}
+	}
+	return words
+}
+
+func TestSessionExpiredSignsInAgain(t *testing.T) {
+	cli, emu := newSignedInClient(t)
+
+	_, err := cli.ListUsers(t.Context())
+	require.NoError(t, err)
+
+	emu.ExpireSession()
+
+	users, err := cli.ListUsers(t.Context())
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, 2, emu.SignIns())
+
+	calls := emu.Calls()
+	require.Equal(t, []string{"user", "list", "--format=json"}, calls[len(calls)-1].Args)
+	require.Contains(t, calls[len(calls)-1].Env, "OP_SESSION_U1=session-2")
+}
+
+func TestSessionExpiredSharedSignIn(t *testing.T) {
+	cli, emu := newSignedInClient(t)
+	emu.ExpireSession()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Go(func() {
+			_, err := cli.ListUsers(t.Context())
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, emu.SignIns())
+}
+
+func TestSessionExpiredWithoutAccountDetails(t *testing.T) {
+	_, emu := newSignedInClient(t)
+	cli := onepassword.NewCli("user", "session-1", emu)
+	emu.ExpireSession()
+
+	_, err := cli.ListUsers(t.Context())
+	require.ErrorContains(t, err, "exit status 1")
+	require.Equal(t, 1, emu.SignIns())
+}
+
+func TestServiceAccountTokenInEnvironment(t *testing.T) {
+	emu := optest.NewEmulator(onepassword.Account{BaseType: onepassword.BaseType{ID: "ACCOUNT1", Name: "Example"}})
+	cli := onepassword.NewCli("service", "ops_token", emu, onepassword.WithConfigDir(t.TempDir()))
+
+	_, err := cli.ListUsers(t.Context())
+	require.NoError(t, err)
+
+	call := emu.Calls()[0]
+	require.Equal(t, []string{"user", "list", "--format=json"}, call.Args)
+	require.Contains(t, call.Env, "OP_SERVICE_ACCOUNT_TOKEN=ops_token")
+}
+
+func TestGetLocalAccountUUIDMatchesAddressAndEmail(t *testing.T) {
+	runner := optest.NewScriptedRunner().OnJSON([]string{"accounts", "list"}, `[
+		{"url": "https://example.1password.com", "email": "alice@example.com", "user_uuid": "U1", "account_uuid": "ACCOUNT1"},
+		{"url": "subsidiary.1password.eu", "email": "Alice@Example.com", "user_uuid": "U9", "account_uuid": "ACCOUNT2"}
+	]`)
+	cli := onepassword.NewCli("user", "", runner, onepassword.WithConfigDir(t.TempDir()))
+
+	for _, tc := range []struct {
+		address, email, expected string
+	}{
+		{"example.1password.com", "alice@example.com", "ACCOUNT1"},
+		{"https://subsidiary.1password.eu/", "alice@example.com", "ACCOUNT2"},
+		{"example.1password.com", "bob@example.com", ""},
+		{"other.1password.com", "alice@example.com", ""},
+	} {
+		uuid, err := cli.GetLocalAccountUUID(t.Context(), tc.address, tc.email)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, uuid, "%s %s", tc.address, tc.email)
+	}
+}
+
+func TestWithAccountScopesCommands(t *testing.T) {
+	for _, tc := range []struct {
+		account string
+		err     string
+	}{
+		{account: "example.1password.com"},
+		{account: "ACCOUNT1"},
+		{account: "elsewhere.1password.com", err: "no account found for filter elsewhere.1password.com"},
+	} {
+		emu := newTestEmulator().RequireSignIn(testPassword)
+
+		details := onepassword.NewAccount("example.1password.com", "alice@example.com", "A3-SECRET", testPassword)
+		cli := onepassword.NewCli("user", "", emu,
+			onepassword.WithAccountDetails(details),
+			onepassword.WithConfigDir(t.TempDir()),
+			onepassword.WithAccount(tc.account),
+		)
+		require.NoError(t, cli.SignInUser(t.Context()))
+
+		_, err := cli.ListUsers(t.Context())
+		if tc.err != "" {
+			require.ErrorContains(t, err, tc.err)
+			continue
+		}
+		require.NoError(t, err)
+
+		calls := emu.Calls()
+		require.Equal(t, []string{"user", "list", "--format=json", "--account", tc.account}, calls[len(calls)-1].Args)
+	}
+}
@@ -0,0 +1,148 @@
+package onepassword
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Version is a release of the 1Password CLI.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses the output of `op --version`, such as 2.30.0 or 2.31.0-beta.01.
+// Pre-release suffixes are ignored.
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	core, _, _ := strings.Cut(s, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid op version %q", s)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid op version %q", s)
+		}
+		numbers[i] = n
+	}
+
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is an older release than other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+var (
+	// MinimumVersion is the oldest op release the connector runs with, older ones lack the JSON output it parses.
+	MinimumVersion = Version{Major: 2, Minor: 0, Patch: 0}
+
+	// Releases from KnownGoodVersion up to, but excluding, NextUntestedVersion are known to work with every feature.
+	// Other releases that satisfy MinimumVersion are used with a warning.
+	KnownGoodVersion    = Version{Major: 2, Minor: 26, Patch: 0}
+	NextUntestedVersion = Version{Major: 3, Minor: 0, Patch: 0}
+)
+
+// Capability is a feature of the connector that needs a newer op release than MinimumVersion.
+type Capability string
+
+const (
+	// CapabilityVaultGroups reads vault access of groups with `op vault group list`, and changes it with `op vault group grant` and `revoke`.
+	// Without it, group grants on vaults are not synced and cannot be provisioned.
+	CapabilityVaultGroups Capability = "vault-groups"
+	// CapabilityGroupRoles grants group roles with `op group user grant --role`.
+	// Without it, members are granted without a role, and manager grants and demotions are refused.
+	CapabilityGroupRoles Capability = "group-roles"
+	// CapabilityRateLimits reads service account quotas with `op service-account ratelimit`.
+	// Without it, the rate budget is disabled and rate limited reads are only retried.
+	CapabilityRateLimits Capability = "rate-limits"
+)
+
+// capabilitySince is the first op release each capability works with.
+var capabilitySince = map[Capability]Version{
+	CapabilityVaultGroups: {Major: 2, Minor: 2, Patch: 0},
+	CapabilityGroupRoles:  {Major: 2, Minor: 3, Patch: 0},
+	CapabilityRateLimits:  {Major: 2, Minor: 26, Patch: 0},
+}
+
+// DetectVersion runs `op --version` and checks the release against MinimumVersion.
+// The features the release does not support are disabled for the lifetime of the client.
+func (c *OnePasswordClient) DetectVersion(ctx context.Context) (Version, error) {
+	l := ctxzap.Extract(ctx)
+
+	res, err := c.run(ctx, []string{"--version"}, nil, c.configEnv())
+	if err != nil {
+		return Version{}, fmt.Errorf("error getting op version: %w", err)
+	}
+	if res.ExitCode != 0 {
+		return Version{}, fmt.Errorf("error getting op version: %w", newCommandError(res))
+	}
+
+	version, err := ParseVersion(string(res.Stdout))
+	if err != nil {
+		return Version{}, err
+	}
+
+	if version.Less(MinimumVersion) {
+		return version, fmt.Errorf("op %s is older than the minimum supported version %s: %w", version, MinimumVersion, ErrUnsupportedVersion)
+	}
+	if version.Less(KnownGoodVersion) || !version.Less(NextUntestedVersion) {
+		l.Warn("op version has not been tested with the connector",
+			zap.Stringer("version", version),
+			zap.Stringer("known_good_from", KnownGoodVersion),
+			zap.Stringer("known_good_before", NextUntestedVersion),
+		)
+	}
+
+	c.version = &version
+
+	return version, nil
+}
+
+// OpVersion returns the op release found by DetectVersion, if it was called.
+func (c *OnePasswordClient) OpVersion() (Version, bool) {
+	if c.version == nil {
+		return Version{}, false
+	}
+	return *c.version, true
+}
+
+// Supports reports whether the op release of the client has the capability.
+// Every capability is assumed to be available until DetectVersion has run.
+func (c *OnePasswordClient) Supports(capability Capability) bool {
+	if c.version == nil {
+		return true
+	}
+	return !c.version.Less(capabilitySince[capability])
+}
+
+// DegradedCapabilities returns the capabilities the op release of the client lacks, in a stable order.
+func (c *OnePasswordClient) DegradedCapabilities() []Capability {
+	var rv []Capability
+	for _, capability := range []Capability{CapabilityVaultGroups, CapabilityGroupRoles, CapabilityRateLimits} {
+		if !c.Supports(capability) {
+			rv = append(rv, capability)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,105 @@
+package onepassword_test
+
+import (
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected onepassword.Version
+		err      bool
+	}{
+		{input: "2.30.0\n", expected: onepassword.Version{Major: 2, Minor: 30}},
+		{input: "v2.4.1", expected: onepassword.Version{Major: 2, Minor: 4, Patch: 1}},
+		{input: "2.31.0-beta.01", expected: onepassword.Version{Major: 2, Minor: 31}},
+		{input: "2.30", err: true},
+		{input: "op version two", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			version, err := onepassword.ParseVersion(tt.input)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, version)
+		})
+	}
+}
+
+func newVersionEmulator(version string) *optest.Emulator {
+	return newTestEmulator().
+		AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}).
+		SetVaultGroupPermissions("V1", "G1", "view_items").
+		SetRateLimits(onepassword.RateLimit{Type: "token", Action: "read_write", Limit: 100, Remaining: 100}).
+		SetVersion(version)
+}
+
+func TestDetectVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		degraded []onepassword.Capability
+		err      error
+	}{
+		{version: "2.30.0"},
+		{version: "3.1.0"},
+		{version: "2.24.1", degraded: []onepassword.Capability{onepassword.CapabilityRateLimits}},
+		{version: "2.1.0", degraded: []onepassword.Capability{
+			onepassword.CapabilityVaultGroups,
+			onepassword.CapabilityGroupRoles,
+			onepassword.CapabilityRateLimits,
+		}},
+		{version: "1.12.4", err: onepassword.ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			cli := onepassword.NewCli("service", "", newVersionEmulator(tt.version))
+
+			version, err := cli.DetectVersion(t.Context())
+			require.Equal(t, tt.version, version.String())
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.degraded, cli.DegradedCapabilities())
+		})
+	}
+}
+
+func TestDegradedCapabilities(t *testing.T) {
+	emu := newVersionEmulator("2.1.0")
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithRateBudget(0.1))
+	_, err := cli.DetectVersion(t.Context())
+	require.NoError(t, err)
+
+	groups, err := cli.ListVaultGroups(t.Context(), "V1")
+	require.NoError(t, err)
+	require.Empty(t, groups)
+
+	err = cli.AddUserToGroup(t.Context(), "G1", "manager", "U1")
+	require.ErrorIs(t, err, onepassword.ErrUnsupportedVersion)
+	require.ErrorIs(t, cli.DemoteGroupManager(t.Context(), "G1", "U1"), onepassword.ErrUnsupportedVersion)
+	require.ErrorIs(t, cli.AddGroupToVault(t.Context(), "V1", "G1", "view_items"), onepassword.ErrUnsupportedVersion)
+	require.ErrorIs(t, cli.RemoveGroupFromVault(t.Context(), "V1", "G1", "view_items"), onepassword.ErrUnsupportedVersion)
+	require.NoError(t, cli.AddUserToGroup(t.Context(), "G1", "member", "U1"))
+
+	require.NoError(t, cli.RefreshRateBudget(t.Context()))
+	require.Nil(t, cli.RateBudgetUsage())
+
+	calls := emu.Calls()
+	require.Equal(t, []string{"--version"}, calls[0].Args)
+	require.Equal(t, []string{"group", "user", "grant", "--group", "G1", "--user", "U1", "--format=json"}, calls[len(calls)-1].Args)
+	require.Zero(t, countCommands(calls, "vault", "group", "list"))
+	require.Zero(t, countCommands(calls, "vault", "group", "grant"))
+	require.Zero(t, countCommands(calls, "vault", "group", "revoke"))
+	require.Zero(t, countCommands(calls, "service-account", "ratelimit"))
+}
@@ -23,7 +23,7 @@ var (
 
 	AuthTypeField = field.StringField(
 		"auth-type",
-		field.WithDescription("Authentication method to use with One Password cli. Limited to 'user' and 'service'. Default: 'user'"),
+		field.WithDescription("Authentication method to use with One Password cli. Limited to 'user', 'service' and 'scim'. Default: 'user'"),
 		field.WithRequired(false),
 		field.WithDefaultValue("user"),
 	)
@@ -49,6 +49,90 @@ var (
 		field.WithDefaultValue("my.1password.com"),
 	)
 
+	AccountsFileField = field.StringField(
+		"accounts-file",
+		field.WithDisplayName("Accounts file"),
+		field.WithDescription("Path of a JSON file listing several 1Password accounts to sync, "+
+			"each with either address, email, secret_key and password, or a service_account_token. Replaces the single account settings"),
+		field.WithRequired(false),
+	)
+
+	SCIMBridgeURLField = field.StringField(
+		"scim-bridge-url",
+		field.WithDisplayName("SCIM bridge URL"),
+		field.WithDescription("URL of your 1Password SCIM bridge. Used to sync and provision users and groups when auth-type is 'scim'"),
+		field.WithRequired(false),
+	)
+
+	SCIMBridgeTokenField = field.StringField(
+		"scim-bridge-token",
+		field.WithDisplayName("SCIM bridge token"),
+		field.WithDescription("Bearer token of your 1Password SCIM bridge"),
+		field.WithRequired(false),
+		field.WithIsSecret(true),
+	)
+
+	EventsAPIURLField = field.StringField(
+		"events-api-url",
+		field.WithDisplayName("Events API URL"),
+		field.WithDescription("URL of the 1Password Events API for your account region. Default: 'https://events.1password.com'"),
+		field.WithRequired(false),
+		field.WithDefaultValue("https://events.1password.com"),
+	)
+
+	EventsAPITokenField = field.StringField(
+		"events-api-token",
+		field.WithDisplayName("Events API token"),
+		field.WithDescription("Bearer token of a 1Password Events Reporting integration. Enables the sign-in attempt, item usage and audit event feeds"),
+		field.WithRequired(false),
+		field.WithIsSecret(true),
+	)
+
+	ProvisioningRateReserveField = field.IntField(
+		"provisioning-rate-reserve",
+		field.WithDisplayName("Provisioning rate reserve"),
+		field.WithDescription("Percentage of the service account rate limits that syncing leaves for provisioning when auth-type is 'service'. Default: 10"),
+		field.WithRequired(false),
+		field.WithDefaultValue(10),
+	)
+
+	OpPathField = field.StringField(
+		"op-path",
+		field.WithDisplayName("Op executable path"),
+		field.WithDescription("Path of the op executable. Default: op, looked up in $PATH"),
+		field.WithRequired(false),
+	)
+
+	OpSHA256Field = field.StringSliceField(
+		"op-sha256",
+		field.WithDisplayName("Op executable SHA-256 digests"),
+		field.WithDescription("Allowed SHA-256 digests of the op executable, hex encoded. The connector refuses to run any other executable"),
+		field.WithRequired(false),
+	)
+
+	OpConcurrencyField = field.IntField(
+		"op-concurrency",
+		field.WithDisplayName("Op concurrency"),
+		field.WithDescription("Number of op commands run at once to prefetch vault and group access during a sync, 1 disables prefetching. Default: 4"),
+		field.WithRequired(false),
+		field.WithDefaultValue(4),
+	)
+
+	AccountDeprovisioningField = field.StringField(
+		"account-deprovisioning",
+		field.WithDisplayName("Account deprovisioning"),
+		field.WithDescription("What revoking the account membership of a user does: 'suspend' the user or 'delete' them. Default: 'suspend'"),
+		field.WithRequired(false),
+		field.WithDefaultValue(string(connector.DeprovisionSuspend)),
+	)
+
+	ForceVaultDeletionField = field.BoolField(
+		"force-vault-deletion",
+		field.WithDisplayName("Force vault deletion"),
+		field.WithDescription("Delete vaults that still hold items, along with their items. Without it, only empty vaults are deleted"),
+		field.WithRequired(false),
+	)
+
 	LimitVaultPermissionsField = field.StringSliceField(
 		"limit-vault-permissions",
 		field.WithDescription("Limit ingested vault permissions: "+strings.Join(sortedVaultPermissions(), ", ")),
@@ -61,14 +145,34 @@ var (
 		AuthTypeField,
 		KeyField,
 		PasswordField,
+		AccountsFileField,
+		SCIMBridgeURLField,
+		SCIMBridgeTokenField,
+		EventsAPIURLField,
+		EventsAPITokenField,
+		ProvisioningRateReserveField,
+		OpPathField,
+		OpSHA256Field,
+		OpConcurrencyField,
+		AccountDeprovisioningField,
+		ForceVaultDeletionField,
 		LimitVaultPermissionsField,
 	}
 
 	FieldRelationships = []field.SchemaFieldRelationship{
-		field.FieldsRequiredTogether(EmailField, AddressField, KeyField, PasswordField),
+		// The address has a default value, so it is always present.
+		field.FieldsRequiredTogether(EmailField, KeyField, PasswordField),
+		field.FieldsRequiredTogether(SCIMBridgeURLField, SCIMBridgeTokenField),
+		// The accounts file lists the credentials of every account, so it replaces the settings of a single account.
+		field.FieldsMutuallyExclusive(AccountsFileField, EmailField),
+		field.FieldsMutuallyExclusive(AccountsFileField, KeyField),
+		field.FieldsMutuallyExclusive(AccountsFileField, PasswordField),
+		field.FieldsMutuallyExclusive(AccountsFileField, SCIMBridgeURLField),
+		field.FieldsMutuallyExclusive(AccountsFileField, EventsAPITokenField),
 	}
 
 	ConfigurationSchema = field.Configuration{
-		Fields: ConfigurationFields,
+		Fields:      ConfigurationFields,
+		Constraints: FieldRelationships,
 	}
 )
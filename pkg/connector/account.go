@@ -2,6 +2,8 @@ package connector
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	onepassword "github.com/conductorone/baton-1password/pkg/client"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -10,11 +12,24 @@ import (
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
 	resource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+)
+
+// DeprovisionPolicy is what revoking the account membership of a user does to them.
+type DeprovisionPolicy string
+
+const (
+	// DeprovisionSuspend suspends the user, who keeps their vaults and can be reactivated.
+	DeprovisionSuspend DeprovisionPolicy = "suspend"
+	// DeprovisionDelete removes the user from the account, along with their group memberships and vault access.
+	DeprovisionDelete DeprovisionPolicy = "delete"
 )
 
 type accountResourceType struct {
 	resourceType *v2.ResourceType
-	cli          *onepassword.OnePasswordClient
+	accounts     *accounts
+	deprovision  DeprovisionPolicy
 }
 
 func (a *accountResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -41,19 +56,23 @@ func accountResource(account onepassword.Account) (*v2.Resource, error) {
 }
 
 func (a *accountResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "accounts")
+
 	var rv []*v2.Resource
 
-	account, err := a.cli.GetAccount(ctx)
-	if err != nil {
-		return nil, "", nil, err
-	}
+	for _, cli := range a.accounts.clients {
+		account, err := cli.GetAccount(ctx)
+		if err != nil {
+			return nil, "", nil, wrapError(err, "failed getting account")
+		}
 
-	ar, err := accountResource(account)
-	if err != nil {
-		return nil, "", nil, err
-	}
+		ar, err := accountResource(account)
+		if err != nil {
+			return nil, "", nil, err
+		}
 
-	rv = append(rv, ar)
+		rv = append(rv, ar)
+	}
 
 	return rv, "", nil, nil
 }
@@ -69,13 +88,26 @@ func (a *accountResourceType) Entitlements(_ context.Context, resource *v2.Resou
 }
 
 func (a *accountResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	var rv []*v2.Grant
-	users, err := a.cli.ListUsers(ctx)
+	ctx = onepassword.WithBudgetPhase(ctx, "account grants")
+
+	cli, err := a.accounts.forAccount(ctx, resource.Id.Resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
+	var rv []*v2.Grant
+	users, err := cli.ListUsers(ctx)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "failed listing users")
+	}
+
 	for _, user := range users {
+		// With the suspend policy, revoking membership suspends the user, so suspended users are not members.
+		// With the delete policy, suspended users stay members until they are deleted.
+		if a.deprovision == DeprovisionSuspend && user.State == "SUSPENDED" {
+			continue
+		}
+
 		userCopy := user
 		ur, err := userResource(userCopy, resource.Id)
 		if err != nil {
@@ -89,9 +121,101 @@ func (a *accountResourceType) Grants(ctx context.Context, resource *v2.Resource,
 	return rv, "", nil, nil
 }
 
-func accountBuilder(cli *onepassword.OnePasswordClient) *accountResourceType {
+// Grant makes a user a member of the account again.
+// A suspended user is reactivated, a deleted one is invited again with the email of the principal.
+func (a *accountResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		return nil, fmt.Errorf("baton-1password: only users can be granted account membership")
+	}
+
+	cli, err := a.accounts.forGrant(ctx, principal, entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+	if !cli.HasCLI() {
+		return nil, uhttp.WrapErrors(codes.FailedPrecondition, "baton-1password: granting account membership requires the op CLI")
+	}
+
+	user, err := cli.GetUser(ctx, principal.Id.Resource)
+	if errors.Is(err, onepassword.ErrNotFound) {
+		// A deleted user comes back with a new ID, so they may have been invited again already.
+		email := principalEmail(principal)
+		if email == "" {
+			return nil, uhttp.WrapErrors(codes.FailedPrecondition,
+				fmt.Sprintf("baton-1password: user %s is not in the account and has no email to invite them with", principal.Id.Resource))
+		}
+
+		user, err = cli.GetUser(ctx, email)
+		if errors.Is(err, onepassword.ErrNotFound) {
+			if _, err := cli.ProvisionUser(ctx, email, principal.DisplayName); err != nil {
+				return nil, wrapError(err, "failed inviting user %s", email)
+			}
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, wrapError(err, "failed getting user %s", principal.Id.Resource)
+	}
+
+	if user.State != "SUSPENDED" {
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	if err := cli.ReactivateUser(ctx, user.ID); err != nil {
+		return nil, wrapError(err, "failed reactivating user %s", user.ID)
+	}
+
+	return nil, nil
+}
+
+// Revoke offboards a user from the account, suspending or deleting them according to the deprovisioning policy.
+func (a *accountResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	principal := grant.Principal
+	if principal.Id.ResourceType != resourceTypeUser.Id {
+		return nil, fmt.Errorf("baton-1password: only users can have account membership revoked")
+	}
+
+	cli, err := a.accounts.forGrant(ctx, principal, grant.Entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+	if !cli.HasCLI() {
+		return nil, uhttp.WrapErrors(codes.FailedPrecondition, "baton-1password: revoking account membership requires the op CLI")
+	}
+
+	user, err := cli.GetUser(ctx, principal.Id.Resource)
+	if errors.Is(err, onepassword.ErrNotFound) {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+	if err != nil {
+		return nil, wrapError(err, "failed getting user %s", principal.Id.Resource)
+	}
+
+	if a.deprovision == DeprovisionDelete {
+		if err := cli.DeleteUser(ctx, user.ID); err != nil {
+			return nil, wrapError(err, "failed deleting user %s", user.ID)
+		}
+		return nil, nil
+	}
+
+	if user.State == "SUSPENDED" {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+	if err := cli.SuspendUser(ctx, user.ID, 0); err != nil {
+		return nil, wrapError(err, "failed suspending user %s", user.ID)
+	}
+
+	return nil, nil
+}
+
+func accountBuilder(accounts *accounts, deprovision DeprovisionPolicy) *accountResourceType {
 	return &accountResourceType{
 		resourceType: resourceTypeAccount,
-		cli:          cli,
+		accounts:     accounts,
+		deprovision:  deprovision,
 	}
 }
@@ -0,0 +1,128 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountListAndGrants(t *testing.T) {
+	tests := []struct {
+		deprovision DeprovisionPolicy
+		expected    []string
+	}{
+		// Suspended users are not members when revoking membership suspends users.
+		{DeprovisionSuspend, []string{"account:ACCOUNT1:member:user:U1"}},
+		// Otherwise they keep their membership until they are deleted.
+		{DeprovisionDelete, []string{"account:ACCOUNT1:member:user:U1", "account:ACCOUNT1:member:user:U2"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.deprovision), func(t *testing.T) {
+			runner := optest.NewScriptedRunner().
+				OnJSON([]string{"account", "get"}, `{"id":"ACCOUNT1","name":"Example","domain":"example","type":"BUSINESS","state":"ACTIVE"}`).
+				OnJSON([]string{"user", "list"}, `[
+					{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE"},
+					{"id":"U2","name":"Bob","email":"bob@example.com","state":"SUSPENDED"}
+				]`)
+			a := accountBuilder(newAccounts(onepassword.NewCli("service", "", runner)), tt.deprovision)
+
+			accounts, _, _, err := a.List(context.Background(), nil, &pagination.Token{})
+			require.NoError(t, err)
+			require.Len(t, accounts, 1)
+			require.Equal(t, "ACCOUNT1", accounts[0].Id.Resource)
+			require.Equal(t, "Example", accounts[0].DisplayName)
+
+			grants, _, _, err := a.Grants(context.Background(), accounts[0], &pagination.Token{})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, grantIDs(grants))
+		})
+	}
+}
+
+func TestAccountListError(t *testing.T) {
+	runner := optest.NewScriptedRunner().
+		On([]string{"account", "get"}, optest.Response{Stderr: "[ERROR] unauthorized", ExitCode: 1})
+	a := accountBuilder(newAccounts(onepassword.NewCli("service", "", runner)), DeprovisionSuspend)
+
+	_, _, _, err := a.List(context.Background(), nil, &pagination.Token{})
+	require.Error(t, err)
+}
+
+func TestAccountMembershipSuspend(t *testing.T) {
+	ctx := t.Context()
+	cli := onepassword.NewCli("service", "", newTestEmulator())
+	a := accountBuilder(newAccounts(cli), DeprovisionSuspend)
+
+	account, err := accountResource(onepassword.Account{BaseType: onepassword.BaseType{ID: "ACCOUNT1", Name: "Example"}})
+	require.NoError(t, err)
+	alice, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}, Email: "alice@example.com"}, account.Id)
+	require.NoError(t, err)
+	membership := grant.NewGrant(account, memberEntitlement, alice)
+
+	annos, err := a.Revoke(ctx, membership)
+	require.NoError(t, err)
+	require.Empty(t, annos)
+	user, err := cli.GetUser(ctx, "U1")
+	require.NoError(t, err)
+	require.Equal(t, "SUSPENDED", user.State)
+
+	annos, err = a.Revoke(ctx, membership)
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyRevoked{}), annos)
+
+	grants, _, _, err := a.Grants(ctx, account, &pagination.Token{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"account:ACCOUNT1:member:user:U2"}, grantIDs(grants))
+
+	annos, err = a.Grant(ctx, alice, ent.NewAssignmentEntitlement(account, memberEntitlement))
+	require.NoError(t, err)
+	require.Empty(t, annos)
+	user, err = cli.GetUser(ctx, "U1")
+	require.NoError(t, err)
+	require.Equal(t, "ACTIVE", user.State)
+
+	annos, err = a.Grant(ctx, alice, ent.NewAssignmentEntitlement(account, memberEntitlement))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyExists{}), annos)
+}
+
+func TestAccountMembershipDelete(t *testing.T) {
+	ctx := t.Context()
+	cli := onepassword.NewCli("service", "", newTestEmulator())
+	a := accountBuilder(newAccounts(cli), DeprovisionDelete)
+
+	account, err := accountResource(onepassword.Account{BaseType: onepassword.BaseType{ID: "ACCOUNT1", Name: "Example"}})
+	require.NoError(t, err)
+	bob, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}, Email: "bob@example.com"}, account.Id)
+	require.NoError(t, err)
+
+	_, err = a.Revoke(ctx, grant.NewGrant(account, memberEntitlement, bob))
+	require.NoError(t, err)
+	_, err = cli.GetUser(ctx, "U2")
+	require.ErrorIs(t, err, onepassword.ErrNotFound)
+
+	annos, err := a.Revoke(ctx, grant.NewGrant(account, memberEntitlement, bob))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyRevoked{}), annos)
+
+	// Granting membership back invites the deleted user again, once.
+	for range 2 {
+		_, err = a.Grant(ctx, bob, ent.NewAssignmentEntitlement(account, memberEntitlement))
+		require.NoError(t, err)
+	}
+	users, err := cli.ListUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	invited, err := cli.GetUser(ctx, "bob@example.com")
+	require.NoError(t, err)
+	require.Equal(t, "Bob Jones", invited.Name)
+	require.Equal(t, "PENDING", invited.State)
+}
@@ -0,0 +1,176 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+)
+
+// AccountConfig holds the credentials of one 1Password account synced by the connector.
+type AccountConfig struct {
+	AuthType string
+	// Token is the service account token, user accounts sign in with Details instead.
+	Token   string
+	Details *onepassword.AccountDetails
+	// Options apply to the client of this account only, after the options shared by every account.
+	Options []onepassword.Option
+}
+
+// accounts routes requests to the op client of the account a resource belongs to.
+// Users, groups and vaults are listed as children of their account resource, whose ID is the account ID.
+type accounts struct {
+	clients []*onepassword.OnePasswordClient
+
+	// byID indexes clients by account ID, as they are looked up.
+	mu   sync.Mutex
+	byID map[string]*onepassword.OnePasswordClient
+}
+
+func newAccounts(clients ...*onepassword.OnePasswordClient) *accounts {
+	return &accounts{
+		clients: clients,
+		byID:    make(map[string]*onepassword.OnePasswordClient),
+	}
+}
+
+// forAccount returns the client of the account with the given ID.
+// With a single account there is nothing to route, and its client is returned without asking op.
+func (a *accounts) forAccount(ctx context.Context, accountID string) (*onepassword.OnePasswordClient, error) {
+	if len(a.clients) == 1 {
+		return a.clients[0], nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if cli, ok := a.byID[accountID]; ok {
+		return cli, nil
+	}
+
+	for _, cli := range a.clients {
+		account, err := cli.GetAccount(ctx)
+		if err != nil {
+			return nil, wrapError(err, "failed getting account")
+		}
+		a.byID[account.ID] = cli
+
+		if account.ID == accountID {
+			return cli, nil
+		}
+	}
+
+	return nil, uhttp.WrapErrors(codes.NotFound, fmt.Sprintf("baton-1password: account %s is not configured", accountID))
+}
+
+// forResource returns the client of the account a user, group or vault resource belongs to.
+func (a *accounts) forResource(ctx context.Context, r *v2.Resource) (*onepassword.OnePasswordClient, error) {
+	if len(a.clients) == 1 {
+		return a.clients[0], nil
+	}
+
+	parent := r.GetParentResourceId()
+	if parent.GetResourceType() != resourceTypeAccount.Id {
+		return nil, uhttp.WrapErrors(codes.FailedPrecondition, fmt.Sprintf(
+			"baton-1password: %s %s has no parent account, unable to tell which account it belongs to",
+			r.GetId().GetResourceType(), r.GetId().GetResource()))
+	}
+
+	return a.forAccount(ctx, parent.GetResource())
+}
+
+// forGrant returns the client of the account the principal of a grant belongs to, checking that the resource belongs to it too.
+// Principals that do not name their account, as in grants read back from a sync, use the account of the resource.
+func (a *accounts) forGrant(ctx context.Context, principal *v2.Resource, r *v2.Resource) (*onepassword.OnePasswordClient, error) {
+	parent := principal.GetParentResourceId()
+	if parent.GetResourceType() != resourceTypeAccount.Id {
+		return a.forResource(ctx, r)
+	}
+
+	if account := r.GetParentResourceId(); account != nil && account.GetResource() != parent.GetResource() {
+		return nil, uhttp.WrapErrors(codes.InvalidArgument, fmt.Sprintf(
+			"baton-1password: %s belongs to account %s, not to account %s of %s",
+			principal.GetId().GetResource(), parent.GetResource(), account.GetResource(), r.GetId().GetResource()))
+	}
+
+	return a.forAccount(ctx, parent.GetResource())
+}
+
+// forNewResource returns the client of the account to create a resource in, and the resource ID of that account.
+// accountID may be empty when a single account is configured.
+func (a *accounts) forNewResource(ctx context.Context, accountID string) (*onepassword.OnePasswordClient, *v2.ResourceId, error) {
+	if accountID == "" && len(a.clients) > 1 {
+		return nil, nil, uhttp.WrapErrors(codes.InvalidArgument,
+			"baton-1password: several accounts are configured, the account to create the resource in is required")
+	}
+
+	cli, err := a.forAccount(ctx, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account, err := cli.GetAccount(ctx)
+	if err != nil {
+		return nil, nil, wrapError(err, "failed getting account")
+	}
+
+	return cli, &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: account.ID}, nil
+}
+
+// forUser finds the account of a user from the user ID alone, as action arguments name users without their account.
+// It returns the client of the account, the resource ID of the account and the user.
+func (a *accounts) forUser(ctx context.Context, userID string) (*onepassword.OnePasswordClient, *v2.ResourceId, onepassword.User, error) {
+	return findInAccounts(ctx, a, "user", userID, (*onepassword.OnePasswordClient).GetUser)
+}
+
+// forGroup finds the account of a group from the group ID alone, as resource deletions name groups without their account.
+// It returns the client of the account, the resource ID of the account and the group.
+func (a *accounts) forGroup(ctx context.Context, groupID string) (*onepassword.OnePasswordClient, *v2.ResourceId, onepassword.Group, error) {
+	return findInAccounts(ctx, a, "group", groupID, (*onepassword.OnePasswordClient).GetGroup)
+}
+
+// forVault finds the account of a vault from the vault ID alone, as resource deletions name vaults without their account.
+// It returns the client of the account, the resource ID of the account and the vault.
+func (a *accounts) forVault(ctx context.Context, vaultID string) (*onepassword.OnePasswordClient, *v2.ResourceId, onepassword.Vault, error) {
+	return findInAccounts(ctx, a, "vault", vaultID, (*onepassword.OnePasswordClient).GetVault)
+}
+
+// findInAccounts gets the object of the given kind and ID from the first account that has it.
+func findInAccounts[T any](
+	ctx context.Context,
+	a *accounts,
+	kind string,
+	id string,
+	get func(cli *onepassword.OnePasswordClient, ctx context.Context, id string) (T, error),
+) (*onepassword.OnePasswordClient, *v2.ResourceId, T, error) {
+	var zero T
+
+	for _, cli := range a.clients {
+		obj, err := get(cli, ctx, id)
+		if errors.Is(err, onepassword.ErrNotFound) && len(a.clients) > 1 {
+			continue
+		}
+		if err != nil {
+			return nil, nil, zero, wrapError(err, "failed getting %s %s", kind, id)
+		}
+
+		account, err := cli.GetAccount(ctx)
+		if err != nil {
+			return nil, nil, zero, wrapError(err, "failed getting account")
+		}
+
+		return cli, &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: account.ID}, obj, nil
+	}
+
+	return nil, nil, zero, uhttp.WrapErrors(codes.NotFound, fmt.Sprintf("baton-1password: %s %s is not in any configured account", kind, id))
+}
+
+// primary returns the client of the first configured account.
+func (a *accounts) primary() *onepassword.OnePasswordClient {
+	return a.clients[0]
+}
@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tokenRouter sends each command to the emulator of the service account token it runs with.
+// Commands without a token, such as `op --version`, go to the first emulator.
+type tokenRouter struct {
+	tokens    []string
+	emulators []*optest.Emulator
+}
+
+func (r *tokenRouter) Run(ctx context.Context, args []string, stdin []byte, env []string) (*onepassword.CommandResult, error) {
+	for i, token := range r.tokens {
+		if slices.Contains(env, "OP_SERVICE_ACCOUNT_TOKEN="+token) {
+			return r.emulators[i].Run(ctx, args, stdin, env)
+		}
+	}
+	return r.emulators[0].Run(ctx, args, stdin, env)
+}
+
+// ran reports whether the emulator ran a command starting with words.
+func ran(emu *optest.Emulator, words string) bool {
+	return slices.ContainsFunc(emu.Calls(), func(c optest.Call) bool {
+		return strings.HasPrefix(strings.Join(c.Args, " "), words+" ")
+	})
+}
+
+func newTwoAccountConnector(t *testing.T) (*OnePassword, *optest.Emulator, *optest.Emulator) {
+	t.Helper()
+
+	first := newTestEmulator()
+	second := optest.NewEmulator(onepassword.Account{
+		BaseType: onepassword.BaseType{ID: "ACCOUNT2", Name: "Subsidiary"},
+		Domain:   "subsidiary",
+		Type:     businessAccountType,
+		State:    "ACTIVE",
+	}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Carol White"}, Email: "carol@subsidiary.com"}).
+		AddGroup(onepassword.Group{BaseType: onepassword.BaseType{ID: "G2", Name: "Finance"}}).
+		AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V2", Name: "Ledgers"}})
+
+	router := &tokenRouter{
+		tokens:    []string{"ops_first", "ops_second"},
+		emulators: []*optest.Emulator{first, second},
+	}
+	op, err := NewWithAccounts(t.Context(), []AccountConfig{
+		{AuthType: "service", Token: "ops_first"},
+		{AuthType: "service", Token: "ops_second"},
+	}, router, nil, DeprovisionSuspend, false)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, op.Close(context.Background()))
+	})
+
+	return op, first, second
+}
+
+func TestAccountsListEveryAccount(t *testing.T) {
+	ctx := t.Context()
+	op, _, _ := newTwoAccountConnector(t)
+
+	accounts, _, _, err := accountBuilder(op.accounts, op.deprovision).List(ctx, nil, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+
+	var groupIDs []string
+	for _, account := range accounts {
+		groups, _, _, err := groupBuilder(op.accounts).List(ctx, account.Id, &pagination.Token{})
+		require.NoError(t, err)
+		for _, group := range groups {
+			require.Equal(t, account.Id.Resource, group.ParentResourceId.Resource)
+			groupIDs = append(groupIDs, account.Id.Resource+"/"+group.Id.Resource)
+		}
+	}
+	require.ElementsMatch(t, []string{"ACCOUNT1/G1", "ACCOUNT2/G2"}, groupIDs)
+
+	grants, _, _, err := accountBuilder(op.accounts, op.deprovision).Grants(ctx, accounts[1], &pagination.Token{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"account:ACCOUNT2:member:user:U3"}, grantIDs(grants))
+}
+
+func TestAccountsRouteProvisioning(t *testing.T) {
+	ctx := t.Context()
+	op, first, second := newTwoAccountConnector(t)
+	g := groupBuilder(op.accounts)
+
+	account := &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: "ACCOUNT2"}
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G2", Name: "Finance"}}, account)
+	require.NoError(t, err)
+	carol, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Carol White"}}, account)
+	require.NoError(t, err)
+
+	_, err = g.Grant(ctx, carol, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.NoError(t, err)
+	require.True(t, ran(second, "group user grant"))
+	require.False(t, ran(first, "group user grant"))
+
+	alice, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}}, testAccountID)
+	require.NoError(t, err)
+	_, err = g.Grant(ctx, alice, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.ErrorContains(t, err, "U1 belongs to account ACCOUNT1, not to account ACCOUNT2 of G2")
+
+	orphan, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G2", Name: "Finance"}}, nil)
+	require.NoError(t, err)
+	_, _, _, err = g.Grants(ctx, orphan, &pagination.Token{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// The account of the principal is enough to route a grant.
+	_, err = g.Revoke(ctx, grant.NewGrant(orphan, memberEntitlement, carol))
+	require.NoError(t, err)
+
+	unknown := &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: "ACCOUNT3"}
+	_, _, _, err = g.List(ctx, unknown, &pagination.Token{})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -2,6 +2,7 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	onepassword "github.com/conductorone/baton-1password/pkg/client"
@@ -9,25 +10,124 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type OnePassword struct {
-	cli                   *onepassword.OnePasswordClient
-	accountDetails        *onepassword.AccountDetails
+	accounts              *accounts
 	limitVaultPermissions mapset.Set[string]
+	deprovision           DeprovisionPolicy
+	forceVaultDeletion    bool
 }
 
-func New(ctx context.Context, authType string, token string, providedAccountDetails *onepassword.AccountDetails, limitVaultPermissions []string) (*OnePassword, error) {
+func New(
+	ctx context.Context,
+	authType string,
+	token string,
+	runner onepassword.CommandRunner,
+	providedAccountDetails *onepassword.AccountDetails,
+	limitVaultPermissions []string,
+	cliOpts ...onepassword.Option,
+) (*OnePassword, error) {
+	return NewWithAccounts(ctx, []AccountConfig{{
+		AuthType: authType,
+		Token:    token,
+		Details:  providedAccountDetails,
+	}}, runner, limitVaultPermissions, DeprovisionSuspend, false, cliOpts...)
+}
+
+// NewWithAccounts creates a connector that syncs several 1Password accounts, each through its own op client.
+// deprovision is what revoking account membership does to a user, and forceVaultDeletion lets vaults that hold items be deleted.
+// cliOpts apply to every client.
+func NewWithAccounts(
+	ctx context.Context,
+	configs []AccountConfig,
+	runner onepassword.CommandRunner,
+	limitVaultPermissions []string,
+	deprovision DeprovisionPolicy,
+	forceVaultDeletion bool,
+	cliOpts ...onepassword.Option,
+) (*OnePassword, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("op-connector: no accounts configured")
+	}
+	if deprovision != DeprovisionSuspend && deprovision != DeprovisionDelete {
+		return nil, fmt.Errorf("op-connector: unknown account deprovisioning policy '%s'", deprovision)
+	}
+
+	clients := make([]*onepassword.OnePasswordClient, 0, len(configs))
+	closeAll := func() {
+		for _, cli := range clients {
+			_ = cli.Close()
+		}
+	}
+
+	for _, config := range configs {
+		cli, err := newAccountClient(ctx, config, runner, cliOpts)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		clients = append(clients, cli)
+	}
+
 	op := &OnePassword{
-		cli:            onepassword.NewCli(authType, token),
-		accountDetails: providedAccountDetails,
+		accounts:           newAccounts(clients...),
+		deprovision:        deprovision,
+		forceVaultDeletion: forceVaultDeletion,
 	}
 	if len(limitVaultPermissions) > 0 {
 		op.limitVaultPermissions = mapset.NewSet(limitVaultPermissions...)
 	}
+
 	return op, nil
 }
 
+// newAccountClient creates the op client of one account, in its own configuration directory, and signs it in.
+func newAccountClient(
+	ctx context.Context,
+	config AccountConfig,
+	runner onepassword.CommandRunner,
+	cliOpts []onepassword.Option,
+) (*onepassword.OnePasswordClient, error) {
+	configDir, err := onepassword.NewConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []onepassword.Option{
+		onepassword.WithAccountDetails(config.Details),
+		onepassword.WithConfigDir(configDir),
+		onepassword.WithCache(onepassword.DefaultCacheTTL),
+		onepassword.WithConcurrency(onepassword.DefaultConcurrency),
+	}
+	// Service account tokens are bound to their account, user sessions are scoped to theirs.
+	if config.AuthType == "user" && config.Details != nil {
+		opts = append(opts, onepassword.WithAccount(config.Details.Address()))
+	}
+
+	opts = append(append(opts, cliOpts...), config.Options...)
+	cli := onepassword.NewCli(config.AuthType, config.Token, runner, opts...)
+
+	if cli.HasCLI() {
+		if _, err := cli.DetectVersion(ctx); err != nil {
+			_ = cli.Close()
+			return nil, fmt.Errorf("op-connector: %w", err)
+		}
+	}
+
+	if config.AuthType == "user" {
+		if err := cli.SignInUser(ctx); err != nil {
+			_ = cli.Close()
+			return nil, fmt.Errorf("unable to get user token: %w", err)
+		}
+	}
+
+	return cli, nil
+}
+
 func (op *OnePassword) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 	return &v2.ConnectorMetadata{
 		DisplayName: "1Password",
@@ -35,19 +135,107 @@ func (op *OnePassword) Metadata(ctx context.Context) (*v2.ConnectorMetadata, err
 	}, nil
 }
 
+// Validate checks that every account can be reached. The features that the op version of an account disables are reported
+// in a google.protobuf.Struct annotation, listing the op version and the disabled capabilities of each such account.
 func (op *OnePassword) Validate(ctx context.Context) (annotations.Annotations, error) {
-	_, err := op.cli.GetSignedInAccount(ctx)
+	// The zero connector, which reports the capabilities of the connector, has no accounts to check.
+	if op.accounts == nil {
+		return nil, nil
+	}
+
+	var degraded []interface{}
+	for _, cli := range op.accounts.clients {
+		accountID, err := validateAccount(ctx, cli)
+		if err != nil {
+			return nil, err
+		}
+
+		version, ok := cli.OpVersion()
+		capabilities := cli.DegradedCapabilities()
+		if !ok || len(capabilities) == 0 {
+			continue
+		}
+		names := make([]interface{}, 0, len(capabilities))
+		for _, capability := range capabilities {
+			names = append(names, string(capability))
+		}
+		degraded = append(degraded, map[string]interface{}{
+			"account":      accountID,
+			"op_version":   version.String(),
+			"capabilities": names,
+		})
+	}
+
+	if len(degraded) == 0 {
+		return nil, nil
+	}
+
+	report, err := structpb.NewStruct(map[string]interface{}{"degraded_capabilities": degraded})
+	if err != nil {
+		return nil, fmt.Errorf("op-connector: failed to report degraded capabilities: %w", err)
+	}
+
+	return annotations.New(report), nil
+}
+
+// validateAccount checks that the account of cli can be reached, and returns its ID when it is signed in through the op CLI.
+func validateAccount(ctx context.Context, cli *onepassword.OnePasswordClient) (string, error) {
+	if err := cli.ValidateSCIMBridge(ctx); err != nil {
+		return "", fmt.Errorf("op-connector: failed to validate SCIM bridge: %w", err)
+	}
+
+	if !cli.HasCLI() {
+		return "", nil
+	}
+
+	account, err := cli.GetSignedInAccount(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("op-connector: failed to get signed in account: %w", err)
+		return "", fmt.Errorf("op-connector: failed to get signed in account: %w", err)
 	}
-	return nil, nil
+
+	if version, ok := cli.OpVersion(); ok {
+		l := ctxzap.Extract(ctx)
+		l.Info("op-connector: op version", zap.Stringer("version", version))
+		if degraded := cli.DegradedCapabilities(); len(degraded) > 0 {
+			l.Warn("op-connector: features disabled by the op version",
+				zap.Stringer("version", version),
+				zap.Any("degraded_capabilities", degraded),
+			)
+		}
+	}
+
+	if err := cli.RefreshRateBudget(ctx); err != nil {
+		ctxzap.Extract(ctx).Warn("op-connector: failed to get service account rate limits", zap.Error(err))
+	}
+
+	return account.AccountUUID, nil
+}
+
+// Close stops the background work of the op clients and removes their configuration directories.
+func (op *OnePassword) Close(_ context.Context) error {
+	if op.accounts == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, cli := range op.accounts.clients {
+		errs = append(errs, cli.Close())
+	}
+	return errors.Join(errs...)
 }
 
 func (op *OnePassword) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
-	return []connectorbuilder.ResourceSyncer{
-		userBuilder(op.cli),
-		groupBuilder(op.cli),
-		accountBuilder(op.cli),
-		vaultBuilder(op.cli, op.limitVaultPermissions),
+	syncers := []connectorbuilder.ResourceSyncer{
+		userBuilder(op.accounts),
+		groupBuilder(op.accounts),
+		accountBuilder(op.accounts, op.deprovision),
 	}
+
+	// Vaults are only available through the op CLI.
+	// The zero connector, which reports the capabilities of the connector, lists them.
+	if op.accounts == nil || op.accounts.primary().HasCLI() {
+		syncers = append(syncers, vaultBuilder(op.accounts, op.limitVaultPermissions, op.forceVaultDeletion))
+	}
+
+	return syncers
 }
@@ -0,0 +1,260 @@
+package connector
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/dotc1z"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	sdkSync "github.com/conductorone/baton-sdk/pkg/sync"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestEmulator() *optest.Emulator {
+	return optest.NewEmulator(onepassword.Account{
+		BaseType: onepassword.BaseType{ID: "ACCOUNT1", Name: "Example"},
+		Domain:   "example",
+		Type:     businessAccountType,
+		State:    "ACTIVE",
+	}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}, Email: "alice@example.com"}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}, Email: "bob@example.com"}).
+		AddGroup(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}).
+		AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}).
+		SetGroupMember("G1", "U2", "MANAGER").
+		SetVaultUserPermissions("V1", "U1", "view_items").
+		SetVaultGroupPermissions("V1", "G1", "view_items", "create_items")
+}
+
+type connectorClient struct {
+	v2.ResourceTypesServiceClient
+	v2.ResourcesServiceClient
+	v2.EntitlementsServiceClient
+	v2.GrantsServiceClient
+	v2.ConnectorServiceClient
+	v2.AssetServiceClient
+	v2.GrantManagerServiceClient
+	v2.ResourceManagerServiceClient
+	v2.ResourceDeleterServiceClient
+	v2.AccountManagerServiceClient
+	v2.CredentialManagerServiceClient
+	v2.EventServiceClient
+	v2.TicketsServiceClient
+	v2.ActionServiceClient
+	v2.ResourceGetterServiceClient
+}
+
+// serveConnector exposes the connector over a local gRPC listener, the same way the connector runner does.
+func serveConnector(t *testing.T, server types.ConnectorServer) types.ConnectorClient {
+	t.Helper()
+
+	lis, err := (&net.ListenConfig{}).Listen(t.Context(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer()
+	v2.RegisterResourceTypesServiceServer(s, server)
+	v2.RegisterResourcesServiceServer(s, server)
+	v2.RegisterEntitlementsServiceServer(s, server)
+	v2.RegisterGrantsServiceServer(s, server)
+	v2.RegisterConnectorServiceServer(s, server)
+	v2.RegisterAssetServiceServer(s, server)
+	v2.RegisterGrantManagerServiceServer(s, server)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &connectorClient{
+		ResourceTypesServiceClient:     v2.NewResourceTypesServiceClient(conn),
+		ResourcesServiceClient:         v2.NewResourcesServiceClient(conn),
+		EntitlementsServiceClient:      v2.NewEntitlementsServiceClient(conn),
+		GrantsServiceClient:            v2.NewGrantsServiceClient(conn),
+		ConnectorServiceClient:         v2.NewConnectorServiceClient(conn),
+		AssetServiceClient:             v2.NewAssetServiceClient(conn),
+		GrantManagerServiceClient:      v2.NewGrantManagerServiceClient(conn),
+		ResourceManagerServiceClient:   v2.NewResourceManagerServiceClient(conn),
+		ResourceDeleterServiceClient:   v2.NewResourceDeleterServiceClient(conn),
+		AccountManagerServiceClient:    v2.NewAccountManagerServiceClient(conn),
+		CredentialManagerServiceClient: v2.NewCredentialManagerServiceClient(conn),
+		EventServiceClient:             v2.NewEventServiceClient(conn),
+		TicketsServiceClient:           v2.NewTicketsServiceClient(conn),
+		ActionServiceClient:            v2.NewActionServiceClient(conn),
+		ResourceGetterServiceClient:    v2.NewResourceGetterServiceClient(conn),
+	}
+}
+
+// newTestConnector creates a connector backed by runner, closed at the end of the test.
+func newTestConnector(t *testing.T, runner onepassword.CommandRunner, opts ...onepassword.Option) *OnePassword {
+	t.Helper()
+
+	op, err := New(t.Context(), "service", "", runner, nil, nil, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, op.Close(context.Background()))
+	})
+
+	return op
+}
+
+func TestNewChecksOpVersion(t *testing.T) {
+	_, err := New(t.Context(), "service", "", newTestEmulator().SetVersion("1.12.4"), nil, nil)
+	require.ErrorIs(t, err, onepassword.ErrUnsupportedVersion)
+	require.ErrorContains(t, err, "op 1.12.4 is older than the minimum supported version 2.0.0")
+
+	op := newTestConnector(t, newTestEmulator().SetVersion("2.24.1"))
+	annos, err := op.Validate(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []onepassword.Capability{onepassword.CapabilityRateLimits}, op.accounts.primary().DegradedCapabilities())
+
+	report := &structpb.Struct{}
+	ok, err := annos.Pick(report)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, map[string]any{
+		"degraded_capabilities": []any{
+			map[string]any{"account": "ACCOUNT1", "op_version": "2.24.1", "capabilities": []any{"rate-limits"}},
+		},
+	}, report.AsMap())
+
+	annos, err = newTestConnector(t, newTestEmulator()).Validate(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, annos)
+}
+
+func TestZeroConnector(t *testing.T) {
+	op := &OnePassword{}
+
+	annos, err := op.Validate(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, annos)
+	require.NoError(t, op.Close(t.Context()))
+	require.Len(t, op.ResourceSyncers(t.Context()), 4)
+}
+
+func TestSyncEndToEnd(t *testing.T) {
+	ctx := t.Context()
+	tmpDir := t.TempDir()
+	c1zPath := filepath.Join(tmpDir, "sync.c1z")
+
+	cb := newTestConnector(t, newTestEmulator())
+	server, err := connectorbuilder.NewConnector(ctx, cb)
+	require.NoError(t, err)
+
+	syncer, err := sdkSync.NewSyncer(ctx, serveConnector(t, server),
+		sdkSync.WithC1ZPath(c1zPath),
+		sdkSync.WithTmpDir(tmpDir),
+	)
+	require.NoError(t, err)
+	require.NoError(t, syncer.Sync(ctx))
+	require.NoError(t, syncer.Close(ctx))
+
+	store, err := dotc1z.NewStore(ctx, c1zPath, dotc1z.WithTmpDir(tmpDir))
+	require.NoError(t, err)
+	defer store.Close(ctx)
+
+	resources, err := store.ListResources(ctx, &v2.ResourcesServiceListResourcesRequest{})
+	require.NoError(t, err)
+	var resourceIDs []string
+	for _, r := range resources.List {
+		resourceIDs = append(resourceIDs, r.Id.ResourceType+":"+r.Id.Resource)
+	}
+	require.ElementsMatch(t, []string{
+		"account:ACCOUNT1",
+		"user:U1",
+		"user:U2",
+		"group:G1",
+		"vault:V1",
+	}, resourceIDs)
+
+	grants, err := store.ListGrants(ctx, &v2.GrantsServiceListGrantsRequest{})
+	require.NoError(t, err)
+	ids := grantIDs(grants.List)
+	require.Subset(t, ids, []string{
+		"account:ACCOUNT1:member:user:U1",
+		"account:ACCOUNT1:member:user:U2",
+		"group:G1:member:user:U2",
+		"group:G1:manager:user:U2",
+		"vault:V1:member:user:U1",
+		"vault:V1:view items:user:U1",
+		"vault:V1:member:group:G1",
+		"vault:V1:create items:group:G1",
+	})
+}
+
+func TestGroupProvisioningRoundTrip(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	g := groupBuilder(newAccounts(onepassword.NewCli("service", "", emu)))
+
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	user, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}}, testAccountID)
+	require.NoError(t, err)
+
+	_, err = g.Grant(ctx, user, ent.NewPermissionEntitlement(group, managerEntitlement))
+	require.NoError(t, err)
+
+	grants, _, _, err := g.Grants(ctx, group, &pagination.Token{})
+	require.NoError(t, err)
+	require.Subset(t, grantIDs(grants), []string{"group:G1:member:user:U1", "group:G1:manager:user:U1"})
+
+	_, err = g.Revoke(ctx, grant.NewGrant(group, memberEntitlement, user))
+	require.NoError(t, err)
+
+	grants, _, _, err = g.Grants(ctx, group, &pagination.Token{})
+	require.NoError(t, err)
+	require.NotContains(t, grantIDs(grants), "group:G1:member:user:U1")
+}
+
+func TestVaultProvisioningRoundTrip(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", emu)), nil, false)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	alice, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}}, testAccountID)
+	require.NoError(t, err)
+	bob, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}}, testAccountID)
+	require.NoError(t, err)
+
+	_, err = v.Grant(ctx, alice, ent.NewPermissionEntitlement(vault, "edit items"))
+	require.NoError(t, err)
+
+	members, err := onepassword.NewCli("service", "", emu).ListVaultMembers(ctx, "V1")
+	require.NoError(t, err)
+	idx := slices.IndexFunc(members, func(u onepassword.User) bool { return u.ID == "U1" })
+	require.GreaterOrEqual(t, idx, 0)
+	require.Subset(t, members[idx].Permissions, []string{"view_items", "view_and_copy_passwords", "edit_items"})
+
+	_, err = v.Revoke(ctx, grant.NewGrant(vault, "view items", alice))
+	require.NoError(t, err)
+
+	members, err = onepassword.NewCli("service", "", emu).ListVaultMembers(ctx, "V1")
+	require.NoError(t, err)
+	require.False(t, slices.ContainsFunc(members, func(u onepassword.User) bool { return u.ID == "U1" }))
+
+	// Bob's access is inherited from the Engineering group, so there is nothing to revoke from him directly.
+	_, err = v.Revoke(ctx, grant.NewGrant(vault, "view items", bob))
+	require.ErrorContains(t, err, "only inherited through membership in Engineering (G1)")
+	require.False(t, ran(emu, "vault user revoke --vault V1 --user U2"))
+	require.ErrorIs(t, err, onepassword.ErrInheritedGrant)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,63 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcCodes maps the failure classes of the client to gRPC codes, so callers can tell retryable failures from permanent ones.
+var grpcCodes = []struct {
+	kind error
+	code codes.Code
+}{
+	{onepassword.ErrNotFound, codes.NotFound},
+	{onepassword.ErrPermissionDenied, codes.PermissionDenied},
+	{onepassword.ErrServiceAccountNotAllowed, codes.PermissionDenied},
+	{onepassword.ErrInheritedGrant, codes.FailedPrecondition},
+	{onepassword.ErrSessionExpired, codes.Unauthenticated},
+	{onepassword.ErrRateLimited, codes.Unavailable},
+	{onepassword.ErrUnavailable, codes.Unavailable},
+	{onepassword.ErrAlreadyMember, codes.AlreadyExists},
+	{onepassword.ErrUnsupportedVersion, codes.FailedPrecondition},
+	{onepassword.ErrBinaryMismatch, codes.FailedPrecondition},
+}
+
+// wrapError adds a gRPC status derived from the failure class of err, keeping err in the chain.
+// An exhausted rate limit is described with a RateLimitDescription in the status details,
+// which the Baton syncer uses to wait until the limit resets.
+func wrapError(err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+
+	code := codes.Unknown
+	for _, c := range grpcCodes {
+		if errors.Is(err, c.kind) {
+			code = c.code
+			break
+		}
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	wrapped := fmt.Errorf("%s: %w", msg, err)
+
+	var rlErr *onepassword.RateLimitError
+	if errors.As(err, &rlErr) {
+		st, detailsErr := status.New(code, "baton-1password: "+msg).WithDetails(&v2.RateLimitDescription{
+			Status:  v2.RateLimitDescription_STATUS_OVERLIMIT,
+			ResetAt: timestamppb.New(rlErr.ResetAt),
+		})
+		if detailsErr == nil {
+			return errors.Join(st.Err(), wrapped)
+		}
+	}
+
+	return uhttp.WrapErrors(code, "baton-1password: "+msg, wrapped)
+}
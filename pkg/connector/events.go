@@ -0,0 +1,291 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	signInAttemptsFeedID = "signin_attempts"
+	itemUsagesFeedID     = "item_usages"
+	auditEventsFeedID    = "audit_events"
+
+	defaultEventsPageSize = 100
+	maxEventsPageSize     = 1000
+)
+
+// signInCategorySuccess is the category of successful sign-in attempts. Failed ones are categorised by what failed,
+// such as credentials_failed or mfa_failed.
+const signInCategorySuccess = "success"
+
+// Audit event object types and actions reported by the Events API.
+const (
+	auditObjectGroup            = "group"
+	auditObjectVault            = "vault"
+	auditObjectGroupMembership  = "gm"
+	auditObjectUserVaultAccess  = "uva"
+	auditObjectGroupVaultAccess = "gva"
+
+	auditActionJoin   = "join"
+	auditActionLeave  = "leave"
+	auditActionGrant  = "grant"
+	auditActionRevoke = "revk"
+)
+
+// EventFeeds returns the Events API backed feeds, if an Events API token is configured.
+func (op *OnePassword) EventFeeds(_ context.Context) []connectorbuilder.EventFeed {
+	// The zero connector, which reports the capabilities of the connector, has no Events API.
+	if op.accounts == nil {
+		return nil
+	}
+
+	// The Events API is only configured for single account connectors.
+	cli := op.accounts.primary()
+	events := cli.Events()
+	if events == nil {
+		return nil
+	}
+
+	return []connectorbuilder.EventFeed{
+		&eventFeed[onepassword.SignInAttempt]{
+			id:         signInAttemptsFeedID,
+			eventTypes: []v2.EventType{v2.EventType_EVENT_TYPE_USAGE},
+			cli:        cli,
+			list:       events.ListSignInAttempts,
+			convert:    signInAttemptEvent,
+		},
+		&eventFeed[onepassword.ItemUsage]{
+			id:         itemUsagesFeedID,
+			eventTypes: []v2.EventType{v2.EventType_EVENT_TYPE_USAGE},
+			cli:        cli,
+			list:       events.ListItemUsages,
+			convert:    itemUsageEvent,
+		},
+		&eventFeed[onepassword.AuditEvent]{
+			id: auditEventsFeedID,
+			eventTypes: []v2.EventType{
+				v2.EventType_EVENT_TYPE_CREATE_GRANT,
+				v2.EventType_EVENT_TYPE_CREATE_REVOKE,
+				v2.EventType_EVENT_TYPE_RESOURCE_CHANGE,
+			},
+			cli:     cli,
+			list:    events.ListAuditEvents,
+			convert: auditEvent,
+		},
+	}
+}
+
+// eventFeed pages through one Events API endpoint, converting its items to Baton events.
+type eventFeed[T any] struct {
+	id         string
+	eventTypes []v2.EventType
+	cli        *onepassword.OnePasswordClient
+	list       func(ctx context.Context, cursor string, limit int, startTime time.Time) (*onepassword.EventsPage[T], error)
+	// convert returns nil for items that have no Baton equivalent.
+	convert func(item T, accountID *v2.ResourceId) (*v2.Event, error)
+}
+
+func (f *eventFeed[T]) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return &v2.EventFeedMetadata{
+		Id:                  f.id,
+		SupportedEventTypes: f.eventTypes,
+	}
+}
+
+func (f *eventFeed[T]) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "events")
+
+	account, err := f.cli.GetAccount(ctx)
+	if err != nil {
+		return nil, nil, nil, wrapError(err, "failed getting account")
+	}
+	accountID := &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: account.ID}
+
+	limit := defaultEventsPageSize
+	if pToken.Size > 0 {
+		limit = min(pToken.Size, maxEventsPageSize)
+	}
+
+	var startTime time.Time
+	if earliestEvent != nil {
+		startTime = earliestEvent.AsTime()
+	}
+
+	page, err := f.list(ctx, pToken.Cursor, limit, startTime)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var rv []*v2.Event
+	for _, item := range page.Items {
+		event, err := f.convert(item, accountID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if event != nil {
+			rv = append(rv, event)
+		}
+	}
+
+	return rv, &pagination.StreamState{Cursor: page.Cursor, HasMore: page.HasMore}, nil, nil
+}
+
+func eventsUserResource(user onepassword.EventsUser, accountID *v2.ResourceId) (*v2.Resource, error) {
+	return userResource(onepassword.User{
+		BaseType: onepassword.BaseType{
+			ID:   user.UUID,
+			Name: user.Name,
+		},
+		Email: user.Email,
+	}, accountID)
+}
+
+// A successful sign-in attempt is a usage of the account by the user signing in.
+// Failed attempts are not usages of the account, and have no Baton equivalent.
+func signInAttemptEvent(attempt onepassword.SignInAttempt, accountID *v2.ResourceId) (*v2.Event, error) {
+	if attempt.Category != signInCategorySuccess || attempt.TargetUser.UUID == "" {
+		return nil, nil
+	}
+
+	actor, err := eventsUserResource(attempt.TargetUser, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v2.Event{
+		Id:         attempt.UUID,
+		OccurredAt: timestamppb.New(attempt.Timestamp),
+		Event: &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				TargetResource: &v2.Resource{Id: accountID},
+				ActorResource:  actor,
+			},
+		},
+	}, nil
+}
+
+// An item usage is a usage of the vault holding the item.
+func itemUsageEvent(usage onepassword.ItemUsage, accountID *v2.ResourceId) (*v2.Event, error) {
+	actor, err := eventsUserResource(usage.User, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: usage.VaultUUID}}, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v2.Event{
+		Id:         usage.UUID,
+		OccurredAt: timestamppb.New(usage.Timestamp),
+		Event: &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				TargetResource: vault,
+				ActorResource:  actor,
+			},
+		},
+	}, nil
+}
+
+// auditEvent maps membership and vault access changes to grant and revoke events,
+// and other group and vault changes to resource change events.
+func auditEvent(event onepassword.AuditEvent, accountID *v2.ResourceId) (*v2.Event, error) {
+	rv := &v2.Event{
+		Id:         event.UUID,
+		OccurredAt: timestamppb.New(event.Timestamp),
+	}
+
+	switch event.ObjectType {
+	case auditObjectGroupMembership:
+		group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: event.ObjectUUID}}, accountID)
+		if err != nil {
+			return nil, err
+		}
+		principal, err := eventsUserResource(onepassword.EventsUser{UUID: event.AuxUUID, Name: event.AuxDetails.Name, Email: event.AuxDetails.Email}, accountID)
+		if err != nil {
+			return nil, err
+		}
+		return withMembershipChange(rv, event.Action, auditActionJoin, auditActionLeave, group, principal)
+
+	case auditObjectUserVaultAccess, auditObjectGroupVaultAccess:
+		vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: event.ObjectUUID}}, accountID)
+		if err != nil {
+			return nil, err
+		}
+		var principal *v2.Resource
+		if event.ObjectType == auditObjectUserVaultAccess {
+			principal, err = eventsUserResource(onepassword.EventsUser{UUID: event.AuxUUID, Name: event.AuxDetails.Name, Email: event.AuxDetails.Email}, accountID)
+		} else {
+			principal, err = groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: event.AuxUUID}}, accountID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return withMembershipChange(rv, event.Action, auditActionGrant, auditActionRevoke, vault, principal)
+
+	case auditObjectGroup, auditObjectVault:
+		resourceType := resourceTypeGroup.Id
+		if event.ObjectType == auditObjectVault {
+			resourceType = resourceTypeVault.Id
+		}
+		rv.Event = &v2.Event_ResourceChangeEvent{
+			ResourceChangeEvent: &v2.ResourceChangeEvent{
+				ResourceId: &v2.ResourceId{
+					ResourceType: resourceType,
+					Resource:     event.ObjectUUID,
+				},
+				ParentResourceId: accountID,
+			},
+		}
+		return rv, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// withMembershipChange fills in a grant or revoke of the member entitlement of resource, depending on action.
+func withMembershipChange(event *v2.Event, action, grantAction, revokeAction string, resource, principal *v2.Resource) (*v2.Event, error) {
+	options := PopulateOptions(resource.DisplayName, memberEntitlement, resource.Id.ResourceType)
+	if resource.Id.ResourceType == resourceTypeVault.Id {
+		options = vaultEntitlementOptions(resource, memberEntitlement)
+	}
+	entitlement := ent.NewAssignmentEntitlement(resource, memberEntitlement, options...)
+
+	switch action {
+	case grantAction:
+		event.Event = &v2.Event_CreateGrantEvent{
+			CreateGrantEvent: &v2.CreateGrantEvent{
+				Entitlement: entitlement,
+				Principal:   principal,
+			},
+		}
+	case revokeAction:
+		event.Event = &v2.Event_CreateRevokeEvent{
+			CreateRevokeEvent: &v2.CreateRevokeEvent{
+				Entitlement: entitlement,
+				Principal:   principal,
+			},
+		}
+	default:
+		// Role and permission updates are picked up by the next sync.
+		return nil, nil
+	}
+
+	return event, nil
+}
+
+var _ connectorbuilder.EventProviderV2 = (*OnePassword)(nil)
@@ -0,0 +1,212 @@
+package connector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testEventsToken = "events-token"
+
+// eventsStandIn serves one page per cursor for each Events API endpoint.
+type eventsStandIn struct {
+	mu       sync.Mutex
+	pages    map[string]map[string]map[string]any
+	requests []map[string]any
+}
+
+func (s *eventsStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer "+testEventsToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"Error":{"Message":"Unauthorized"}}`))
+		return
+	}
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	s.requests = append(s.requests, body)
+
+	cursor, _ := body["cursor"].(string)
+	page, ok := s.pages[r.URL.Path][cursor]
+	if !ok {
+		page = map[string]any{"cursor": cursor, "has_more": false, "items": []any{}}
+	}
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+func newEventsTestConnector(t *testing.T, standIn *eventsStandIn, token string) *OnePassword {
+	t.Helper()
+
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	events, err := onepassword.NewEventsAPIClient(t.Context(), server.URL, token)
+	require.NoError(t, err)
+
+	return newTestConnector(t, newTestEmulator(), onepassword.WithEventsAPI(events))
+}
+
+func eventFeedByID(t *testing.T, op *OnePassword, id string) connectorbuilder.EventFeed {
+	t.Helper()
+
+	for _, feed := range op.EventFeeds(t.Context()) {
+		if feed.EventFeedMetadata(t.Context()).GetId() == id {
+			return feed
+		}
+	}
+	require.Failf(t, "event feed not found", "%s", id)
+	return nil
+}
+
+func TestEventFeedsRegistered(t *testing.T) {
+	op := newTestConnector(t, newTestEmulator())
+	require.Empty(t, op.EventFeeds(t.Context()))
+
+	op = newEventsTestConnector(t, &eventsStandIn{}, testEventsToken)
+	server, err := connectorbuilder.NewConnector(t.Context(), op)
+	require.NoError(t, err)
+
+	res, err := server.ListEventFeeds(t.Context(), &v2.ListEventFeedsRequest{})
+	require.NoError(t, err)
+	var ids []string
+	for _, feed := range res.GetList() {
+		ids = append(ids, feed.GetId())
+	}
+	require.ElementsMatch(t, []string{signInAttemptsFeedID, itemUsagesFeedID, auditEventsFeedID}, ids)
+}
+
+func TestAuditEventsFeed(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	standIn := &eventsStandIn{pages: map[string]map[string]map[string]any{
+		"/api/v1/auditevents": {
+			"": {
+				"cursor":   "c1",
+				"has_more": true,
+				"items": []map[string]any{
+					{"uuid": "E1", "timestamp": ts, "action": "join", "object_type": "gm", "object_uuid": "G1", "aux_uuid": "U1", "aux_details": map[string]any{"name": "Alice Smith", "email": "alice@example.com"}},
+					{"uuid": "E2", "timestamp": ts, "action": "grant", "object_type": "gva", "object_uuid": "V1", "aux_uuid": "G1"},
+					{"uuid": "E3", "timestamp": ts, "action": "sspd", "object_type": "user", "object_uuid": "U2"},
+				},
+			},
+			"c1": {
+				"cursor":   "c2",
+				"has_more": false,
+				"items": []map[string]any{
+					{"uuid": "E4", "timestamp": ts, "action": "revk", "object_type": "uva", "object_uuid": "V1", "aux_uuid": "U1"},
+					{"uuid": "E5", "timestamp": ts, "action": "updt", "object_type": "vault", "object_uuid": "V1"},
+				},
+			},
+		},
+	}}
+	feed := eventFeedByID(t, newEventsTestConnector(t, standIn, testEventsToken), auditEventsFeedID)
+
+	events, state, _, err := feed.ListEvents(t.Context(), timestamppb.New(ts), &pagination.StreamToken{Size: 50})
+	require.NoError(t, err)
+	require.Equal(t, &pagination.StreamState{Cursor: "c1", HasMore: true}, state)
+	require.Len(t, events, 2)
+	require.Equal(t, map[string]any{"limit": float64(50), "start_time": "2024-05-01T12:00:00Z"}, standIn.requests[0])
+
+	join := events[0].GetCreateGrantEvent()
+	require.NotNil(t, join)
+	require.Equal(t, "group:G1:member", join.GetEntitlement().GetId())
+	require.Equal(t, "U1", join.GetPrincipal().GetId().GetResource())
+	require.Equal(t, "Alice Smith", join.GetPrincipal().GetDisplayName())
+
+	vaultGrant := events[1].GetCreateGrantEvent()
+	require.NotNil(t, vaultGrant)
+	require.Equal(t, "vault:V1:member", vaultGrant.GetEntitlement().GetId())
+	require.Equal(t, "group", vaultGrant.GetPrincipal().GetId().GetResourceType())
+
+	events, state, _, err = feed.ListEvents(t.Context(), nil, &pagination.StreamToken{Cursor: state.Cursor})
+	require.NoError(t, err)
+	require.Equal(t, &pagination.StreamState{Cursor: "c2", HasMore: false}, state)
+	require.Equal(t, map[string]any{"cursor": "c1"}, standIn.requests[1])
+	require.Len(t, events, 2)
+
+	revoke := events[0].GetCreateRevokeEvent()
+	require.NotNil(t, revoke)
+	require.Equal(t, "vault:V1:member", revoke.GetEntitlement().GetId())
+	require.Equal(t, "user:U1", revoke.GetPrincipal().GetId().GetResourceType()+":"+revoke.GetPrincipal().GetId().GetResource())
+
+	change := events[1].GetResourceChangeEvent()
+	require.NotNil(t, change)
+	require.Equal(t, "vault", change.GetResourceId().GetResourceType())
+	require.Equal(t, "ACCOUNT1", change.GetParentResourceId().GetResource())
+}
+
+func TestUsageEventFeeds(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	alice := map[string]any{"uuid": "U1", "name": "Alice Smith", "email": "alice@example.com"}
+	standIn := &eventsStandIn{pages: map[string]map[string]map[string]any{
+		"/api/v1/signinattempts": {
+			"": {"cursor": "s1", "items": []map[string]any{
+				{"uuid": "S1", "timestamp": ts, "category": "success", "type": "credentials_ok", "target_user": alice},
+			}},
+		},
+		"/api/v1/itemusages": {
+			"": {"cursor": "i1", "items": []map[string]any{
+				{"uuid": "I1", "timestamp": ts, "vault_uuid": "V1", "item_uuid": "ITEM1", "action": "reveal", "user": alice},
+			}},
+		},
+	}}
+	op := newEventsTestConnector(t, standIn, testEventsToken)
+
+	events, state, _, err := eventFeedByID(t, op, signInAttemptsFeedID).ListEvents(t.Context(), nil, &pagination.StreamToken{})
+	require.NoError(t, err)
+	require.Equal(t, "s1", state.Cursor)
+	require.Len(t, events, 1)
+	signIn := events[0].GetUsageEvent()
+	require.Equal(t, "account", signIn.GetTargetResource().GetId().GetResourceType())
+	require.Equal(t, "U1", signIn.GetActorResource().GetId().GetResource())
+	require.Equal(t, "Alice Smith", signIn.GetActorResource().GetDisplayName())
+	require.Equal(t, ts, events[0].GetOccurredAt().AsTime())
+
+	events, _, _, err = eventFeedByID(t, op, itemUsagesFeedID).ListEvents(t.Context(), nil, &pagination.StreamToken{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	usage := events[0].GetUsageEvent()
+	require.Equal(t, "vault:V1", usage.GetTargetResource().GetId().GetResourceType()+":"+usage.GetTargetResource().GetId().GetResource())
+	require.Equal(t, "U1", usage.GetActorResource().GetId().GetResource())
+}
+
+func TestFailedSignInAttemptsAreSkipped(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	alice := map[string]any{"uuid": "U1", "name": "Alice Smith", "email": "alice@example.com"}
+	standIn := &eventsStandIn{pages: map[string]map[string]map[string]any{
+		"/api/v1/signinattempts": {
+			"": {"cursor": "s1", "items": []map[string]any{
+				{"uuid": "S1", "timestamp": ts, "category": "credentials_failed", "type": "password_secret_bad", "target_user": alice},
+				{"uuid": "S2", "timestamp": ts, "category": "mfa_failed", "type": "mfa_missing", "target_user": alice},
+				{"uuid": "S3", "timestamp": ts, "category": "success", "type": "credentials_ok", "target_user": alice},
+			}},
+		},
+	}}
+	feed := eventFeedByID(t, newEventsTestConnector(t, standIn, testEventsToken), signInAttemptsFeedID)
+
+	events, state, _, err := feed.ListEvents(t.Context(), nil, &pagination.StreamToken{})
+	require.NoError(t, err)
+	require.Equal(t, "s1", state.Cursor)
+	require.Len(t, events, 1)
+	require.Equal(t, "S3", events[0].GetId())
+}
+
+func TestEventsUnauthorized(t *testing.T) {
+	feed := eventFeedByID(t, newEventsTestConnector(t, &eventsStandIn{}, "wrong-token"), auditEventsFeedID)
+
+	_, _, _, err := feed.ListEvents(t.Context(), nil, &pagination.StreamToken{})
+	require.ErrorContains(t, err, "Unauthorized")
+}
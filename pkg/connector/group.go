@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	onepassword "github.com/conductorone/baton-1password/pkg/client"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -12,19 +13,24 @@ import (
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	grant "github.com/conductorone/baton-sdk/pkg/types/grant"
 	resource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 type groupResourceType struct {
 	resourceType *v2.ResourceType
-	cli          *onepassword.OnePasswordClient
+	accounts     *accounts
 }
 
 const (
 	memberEntitlement  = "member"
 	managerEntitlement = "manager"
 	manager            = "MANAGER"
+
+	// userDefinedGroupType is the type of the groups created in the account, as opposed to built-in ones.
+	userDefinedGroupType = "USER_DEFINED"
 )
 
 func (g *groupResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -56,17 +62,25 @@ func groupResource(group onepassword.Group, parentResourceID *v2.ResourceId) (*v
 }
 
 func (g *groupResourceType) List(ctx context.Context, parentId *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "groups")
+
 	if parentId == nil {
 		return nil, "", nil, nil
 	}
 
+	cli, err := g.accounts.forAccount(ctx, parentId.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	var rv []*v2.Resource
 
-	groups, err := g.cli.ListGroups(ctx)
+	groups, err := cli.ListGroups(ctx)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, wrapError(err, "failed listing groups")
 	}
 
+	groupIDs := make([]string, 0, len(groups))
 	for _, group := range groups {
 		groupCopy := group
 		gr, err := groupResource(groupCopy, parentId)
@@ -74,8 +88,11 @@ func (g *groupResourceType) List(ctx context.Context, parentId *v2.ResourceId, _
 			return nil, "", nil, err
 		}
 		rv = append(rv, gr)
+		groupIDs = append(groupIDs, group.ID)
 	}
 
+	cli.PrefetchGroupMembers(onepassword.WithBudgetPhase(ctx, "group grants"), groupIDs)
+
 	return rv, "", nil, nil
 }
 
@@ -94,13 +111,20 @@ func (g *groupResourceType) Entitlements(_ context.Context, resource *v2.Resourc
 }
 
 func (g *groupResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	var rv []*v2.Grant
+	ctx = onepassword.WithBudgetPhase(ctx, "group grants")
 
-	groupMembers, err := g.cli.ListGroupMembers(ctx, resource.Id.Resource)
+	cli, err := g.accounts.forResource(ctx, resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
+	var rv []*v2.Grant
+
+	groupMembers, err := cli.ListGroupMembers(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "failed listing members of group %s", resource.Id.Resource)
+	}
+
 	for _, member := range groupMembers {
 		memberCopy := member
 		ur, err := userResource(memberCopy, resource.Id)
@@ -120,7 +144,13 @@ func (g *groupResourceType) Grants(ctx context.Context, resource *v2.Resource, _
 	return rv, "", nil, nil
 }
 
+// Grant makes a user a member or a manager of a group.
+// The current role of the user decides what to change: a manager already holds membership,
+// and a user outside the group becomes a member before being promoted. Users who hold the entitlement already are left alone
+// and the grant is annotated with GrantAlreadyExists.
 func (o *groupResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
 	if principal.Id.ResourceType != resourceTypeUser.Id {
 		return nil, fmt.Errorf("baton-1password: only users can be granted group membership")
 	}
@@ -130,16 +160,55 @@ func (o *groupResourceType) Grant(ctx context.Context, principal *v2.Resource, e
 		return nil, fmt.Errorf("could not extract role: %w", err)
 	}
 
-	err = o.cli.AddUserToGroup(ctx, entitlement.Resource.Id.Resource, role, principal.Id.Resource)
+	cli, err := o.accounts.forGrant(ctx, principal, entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := resolveUser(ctx, cli, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	groupID := entitlement.Resource.Id.Resource
+	current, err := groupRole(ctx, cli, groupID, user.ID)
+	if err != nil {
+		return nil, err
+	}
 
+	switch role {
+	case managerEntitlement:
+		if current == manager {
+			return annotations.New(&v2.GrantAlreadyExists{}), nil
+		}
+		if current == "" {
+			if err := cli.AddUserToGroup(ctx, groupID, memberEntitlement, user.ID); err != nil && !errors.Is(err, onepassword.ErrAlreadyMember) {
+				return nil, wrapError(err, "failed adding user to group")
+			}
+		}
+		err = cli.AddUserToGroup(ctx, groupID, managerEntitlement, user.ID)
+	default:
+		// Granting the member role to a manager would demote them.
+		if current != "" {
+			return annotations.New(&v2.GrantAlreadyExists{}), nil
+		}
+		err = cli.AddUserToGroup(ctx, groupID, role, user.ID)
+	}
+	if errors.Is(err, onepassword.ErrAlreadyMember) {
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("baton-1password: failed adding user to group")
+		return nil, wrapError(err, "failed adding user to group")
 	}
 
 	return nil, nil
 }
 
+// Revoke removes a user from a group, or for the manager entitlement, demotes a manager to a member of the group.
+// Users who do not hold the entitlement are left alone and the revoke is annotated with GrantAlreadyRevoked.
 func (o *groupResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
 	l := ctxzap.Extract(ctx)
 
 	entitlement := grant.Entitlement
@@ -154,18 +223,146 @@ func (o *groupResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annota
 		return nil, errors.New("baton-1password: only users can have group membership revoked")
 	}
 
-	err := o.cli.RemoveUserFromGroup(ctx, entitlement.Resource.Id.Resource, principal.Id.Resource)
+	role, err := extractRoleFromEntitlementID(entitlement.Id)
+	if err != nil {
+		return nil, fmt.Errorf("could not extract role: %w", err)
+	}
 
+	cli, err := o.accounts.forGrant(ctx, principal, entitlement.Resource)
 	if err != nil {
-		return nil, errors.New("baton-1password: failed removing user from group")
+		return nil, err
+	}
+
+	user, err := resolveUser(ctx, cli, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	groupID := entitlement.Resource.Id.Resource
+	current, err := groupRole(ctx, cli, groupID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == managerEntitlement {
+		if current != manager {
+			return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+		}
+
+		if err := cli.DemoteGroupManager(ctx, groupID, user.ID); err != nil {
+			return nil, wrapError(err, "failed demoting group manager")
+		}
+		return nil, nil
+	}
+
+	if current == "" {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	err = cli.RemoveUserFromGroup(ctx, groupID, user.ID)
+	if err != nil {
+		return nil, wrapError(err, "failed removing user from group")
 	}
 
 	return nil, nil
 }
 
-func groupBuilder(cli *onepassword.OnePasswordClient) *groupResourceType {
+// groupRole returns the role of a user in a group, MEMBER or MANAGER, or an empty string when they are not in the group.
+func groupRole(ctx context.Context, cli *onepassword.OnePasswordClient, groupID, userID string) (string, error) {
+	members, err := cli.ListGroupMembers(ctx, groupID)
+	if err != nil {
+		return "", wrapError(err, "failed listing members of group %s", groupID)
+	}
+
+	for _, member := range members {
+		if member.ID != userID {
+			continue
+		}
+		// Releases of op without group roles list members without one.
+		if member.Role == "" {
+			return "MEMBER", nil
+		}
+		return strings.ToUpper(member.Role), nil
+	}
+
+	return "", nil
+}
+
+// Create creates a group with the display name and description of resource, in the account of its parent.
+func (g *groupResourceType) Create(ctx context.Context, resource *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "provisioning")
+
+	name := resource.GetDisplayName()
+	if name == "" {
+		return nil, nil, uhttp.WrapErrors(codes.InvalidArgument, "baton-1password: a name is required to create a group")
+	}
+
+	cli, accountID, err := g.accounts.forNewResource(ctx, resource.GetParentResourceId().GetResource())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cli.HasCLI() {
+		return nil, nil, uhttp.WrapErrors(codes.FailedPrecondition, "baton-1password: creating groups requires the op CLI")
+	}
+
+	group, err := cli.CreateGroup(ctx, name, resource.GetDescription())
+	if err != nil {
+		return nil, nil, wrapError(err, "failed creating group %s", name)
+	}
+
+	gr, err := groupResource(group, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return gr, nil, nil
+}
+
+// Delete deletes a group. Built-in groups are refused.
+func (g *groupResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	cli, _, group, err := g.accounts.forGroup(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, err
+	}
+	if !cli.HasCLI() {
+		return nil, uhttp.WrapErrors(codes.FailedPrecondition, "baton-1password: deleting groups requires the op CLI")
+	}
+
+	if err := checkGroupDeletable(group); err != nil {
+		return nil, err
+	}
+
+	if err := cli.DeleteGroup(ctx, group.ID); err != nil {
+		return nil, wrapError(err, "failed deleting group %s", group.ID)
+	}
+
+	return nil, nil
+}
+
+// checkGroupDeletable refuses to delete the built-in groups of the account, such as Owners, Administrators and Recovery,
+// which have a type other than USER_DEFINED, as well as groups that are not active.
+func checkGroupDeletable(group onepassword.Group) error {
+	if group.Type == "" {
+		return uhttp.WrapErrors(codes.FailedPrecondition,
+			fmt.Sprintf("baton-1password: op does not report the type of group %s (%s), so it cannot be told apart from a built-in group", group.Name, group.ID))
+	}
+	if group.Type != userDefinedGroupType {
+		return uhttp.WrapErrors(codes.FailedPrecondition,
+			fmt.Sprintf("baton-1password: group %s (%s) is a built-in group of type '%s' and cannot be deleted", group.Name, group.ID, group.Type))
+	}
+	if group.State != "ACTIVE" {
+		return uhttp.WrapErrors(codes.FailedPrecondition,
+			fmt.Sprintf("baton-1password: group %s (%s) is in state '%s' and cannot be deleted", group.Name, group.ID, group.State))
+	}
+
+	return nil
+}
+
+func groupBuilder(accounts *accounts) *groupResourceType {
 	return &groupResourceType{
 		resourceType: resourceTypeGroup,
-		cli:          cli,
+		accounts:     accounts,
 	}
 }
@@ -0,0 +1,364 @@
+package connector
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testAccountID = &v2.ResourceId{ResourceType: resourceTypeAccount.Id, Resource: "ACCOUNT1"}
+
+func TestGroupGrants(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  string
+		expected []string
+	}{
+		{
+			name:     "no members",
+			members:  `[]`,
+			expected: nil,
+		},
+		{
+			name:     "member",
+			members:  `[{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE","role":"MEMBER"}]`,
+			expected: []string{"group:G1:member:user:U1"},
+		},
+		{
+			name:    "manager",
+			members: `[{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE","role":"MANAGER"}]`,
+			expected: []string{
+				"group:G1:member:user:U1",
+				"group:G1:manager:user:U1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := optest.NewScriptedRunner().
+				OnJSON([]string{"group", "user", "list", "G1"}, tt.members)
+			g := groupBuilder(newAccounts(onepassword.NewCli("service", "", runner)))
+
+			group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+			require.NoError(t, err)
+
+			grants, _, _, err := g.Grants(context.Background(), group, &pagination.Token{})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, grantIDs(grants))
+		})
+	}
+}
+
+func TestGroupGrant(t *testing.T) {
+	tests := []struct {
+		name        string
+		entitlement string
+		members     string
+		expected    [][]string
+	}{
+		{
+			name:        "member",
+			entitlement: memberEntitlement,
+			members:     `[]`,
+			expected: [][]string{
+				{"group", "user", "grant", "--group", "G1", "--role", "member", "--user", "U1", "--format=json"},
+			},
+		},
+		{
+			name:        "manager",
+			entitlement: managerEntitlement,
+			members:     `[]`,
+			expected: [][]string{
+				{"group", "user", "grant", "--group", "G1", "--role", "member", "--user", "U1", "--format=json"},
+				{"group", "user", "grant", "--group", "G1", "--role", "manager", "--user", "U1", "--format=json"},
+			},
+		},
+		{
+			name:        "promote member",
+			entitlement: managerEntitlement,
+			members:     `[{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE","role":"MEMBER"}]`,
+			expected: [][]string{
+				{"group", "user", "grant", "--group", "G1", "--role", "manager", "--user", "U1", "--format=json"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := optest.NewScriptedRunner().
+				OnJSON([]string{"user", "list"}, `[{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE"}]`).
+				OnJSON([]string{"group", "user", "list", "G1"}, tt.members).
+				OnJSON([]string{"group", "user", "grant"}, "")
+			g := groupBuilder(newAccounts(onepassword.NewCli("service", "", runner)))
+
+			group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+			require.NoError(t, err)
+			user, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice"}}, testAccountID)
+			require.NoError(t, err)
+
+			_, err = g.Grant(context.Background(), user, ent.NewAssignmentEntitlement(group, tt.entitlement))
+			require.NoError(t, err)
+
+			// The first calls resolve the user and read their role in the group.
+			var grants [][]string
+			for _, call := range runner.Calls() {
+				if call.Args[2] == "grant" {
+					grants = append(grants, call.Args)
+				}
+			}
+			require.Equal(t, tt.expected, grants)
+		})
+	}
+}
+
+func TestGroupRoleChanges(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu)
+	g := groupBuilder(newAccounts(cli))
+
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	bob, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}}, testAccountID)
+	require.NoError(t, err)
+
+	role := func() string {
+		t.Helper()
+		role, err := groupRole(ctx, cli, "G1", "U2")
+		require.NoError(t, err)
+		return role
+	}
+
+	// Granting membership to a manager leaves them a manager.
+	annos, err := g.Grant(ctx, bob, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyExists{}), annos)
+	require.Equal(t, manager, role())
+
+	// Revoking manager rights demotes Bob and keeps him in the group.
+	annos, err = g.Revoke(ctx, grant.NewGrant(group, managerEntitlement, bob))
+	require.NoError(t, err)
+	require.Nil(t, annos)
+	require.True(t, ran(emu, "group user grant --group G1 --role member --user U2"))
+	require.False(t, ran(emu, "group user revoke"))
+	require.Equal(t, "MEMBER", role())
+
+	annos, err = g.Revoke(ctx, grant.NewGrant(group, managerEntitlement, bob))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyRevoked{}), annos)
+
+	// Promoting a member takes a single grant.
+	_, err = g.Grant(ctx, bob, ent.NewPermissionEntitlement(group, managerEntitlement))
+	require.NoError(t, err)
+	require.Equal(t, manager, role())
+
+	annos, err = g.Grant(ctx, bob, ent.NewPermissionEntitlement(group, managerEntitlement))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyExists{}), annos)
+
+	promotions := 0
+	for _, call := range emu.Calls() {
+		if slices.Contains(call.Args, "manager") {
+			promotions++
+		}
+	}
+	require.Equal(t, 1, promotions)
+
+	alice, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}}, testAccountID)
+	require.NoError(t, err)
+	annos, err = g.Revoke(ctx, grant.NewGrant(group, memberEntitlement, alice))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyRevoked{}), annos)
+	require.False(t, ran(emu, "group user revoke"))
+}
+
+func TestGroupGrantReadsCurrentMembership(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(onepassword.DefaultCacheTTL))
+	g := groupBuilder(newAccounts(cli))
+
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	bob, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}}, testAccountID)
+	require.NoError(t, err)
+
+	// A sync caches Bob's membership, and he is then removed from the group outside of the connector.
+	members, err := cli.ListGroupMembers(ctx, "G1")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.NoError(t, onepassword.NewCli("service", "", emu).RemoveUserFromGroup(ctx, "G1", "U2"))
+
+	annos, err := g.Grant(ctx, bob, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.NoError(t, err)
+	require.Nil(t, annos)
+	require.True(t, ran(emu, "group user grant --group G1 --role member --user U2"))
+}
+
+func TestGroupGrantErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		code     codes.Code
+		expected annotations.Annotations
+	}{
+		{
+			name:   "group not found",
+			stderr: `[ERROR] 2024/05/01 12:00:00 "G1" isn't a group in this account. Specify the group with its UUID or name.`,
+			code:   codes.NotFound,
+		},
+		{
+			name:   "rate limited",
+			stderr: `[ERROR] 2024/05/01 12:00:00 Too many requests (429)`,
+			code:   codes.Unavailable,
+		},
+		{
+			name:     "already a member",
+			stderr:   `[ERROR] 2024/05/01 12:00:00 "U1" is already a member of the group`,
+			code:     codes.OK,
+			expected: annotations.New(&v2.GrantAlreadyExists{}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := optest.NewScriptedRunner().
+				OnJSON([]string{"user", "list"}, `[{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE"}]`).
+				OnJSON([]string{"group", "user", "list", "G1"}, `[]`).
+				On([]string{"group", "user", "grant"}, optest.Response{Stderr: tt.stderr, ExitCode: 1})
+			g := groupBuilder(newAccounts(onepassword.NewCli("service", "", runner)))
+
+			group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+			require.NoError(t, err)
+			user, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice"}}, testAccountID)
+			require.NoError(t, err)
+
+			annos, err := g.Grant(context.Background(), user, ent.NewAssignmentEntitlement(group, memberEntitlement))
+			require.Equal(t, tt.code, status.Code(err))
+			require.Equal(t, tt.expected, annos)
+			if err != nil {
+				require.ErrorContains(t, err, tt.stderr[len("[ERROR] 2024/05/01 12:00:00 "):])
+			}
+			if tt.code == codes.Unavailable {
+				st, _ := status.FromError(err)
+				require.Len(t, st.Details(), 1)
+				require.Equal(t, v2.RateLimitDescription_STATUS_OVERLIMIT, st.Details()[0].(*v2.RateLimitDescription).GetStatus())
+			}
+		})
+	}
+}
+
+func grantIDs(grants []*v2.Grant) []string {
+	var rv []string
+	for _, g := range grants {
+		rv = append(rv, g.Id)
+	}
+	return rv
+}
+
+func TestGroupCreateAndDelete(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu)
+	g := groupBuilder(newAccounts(cli))
+
+	created, _, err := g.Create(ctx, &v2.Resource{
+		DisplayName:      "Project Falcon",
+		Description:      "Access for the Falcon project",
+		ParentResourceId: testAccountID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Project Falcon", created.DisplayName)
+	require.Equal(t, testAccountID.Resource, created.ParentResourceId.Resource)
+	require.True(t, ran(emu, "group create Project Falcon --description Access for the Falcon project"))
+
+	group, err := cli.GetGroup(ctx, created.Id.Resource)
+	require.NoError(t, err)
+	require.Equal(t, "Access for the Falcon project", group.Description)
+
+	_, err = g.Delete(ctx, created.Id)
+	require.NoError(t, err)
+	_, err = cli.GetGroup(ctx, created.Id.Resource)
+	require.ErrorIs(t, err, onepassword.ErrNotFound)
+
+	_, err = g.Delete(ctx, created.Id)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, _, err = g.Create(ctx, &v2.Resource{ParentResourceId: testAccountID})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGroupDeleteReadsCurrentGroup(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(onepassword.DefaultCacheTTL))
+	g := groupBuilder(newAccounts(cli))
+
+	// A sync caches the group, and it is then deleted outside of the connector.
+	created, err := cli.CreateGroup(ctx, "Project Falcon", "")
+	require.NoError(t, err)
+	_, err = cli.GetGroup(ctx, created.ID)
+	require.NoError(t, err)
+	require.NoError(t, onepassword.NewCli("service", "", emu).DeleteGroup(ctx, created.ID))
+
+	_, err = g.Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: created.ID})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	deletions := 0
+	for _, call := range emu.Calls() {
+		if call.Args[0] == "group" && call.Args[1] == "delete" {
+			deletions++
+		}
+	}
+	require.Equal(t, 1, deletions)
+}
+
+func TestGroupDeleteRequiresTheCLIOfTheGroupAccount(t *testing.T) {
+	// The second account is reached through a SCIM bridge alone.
+	second := optest.NewEmulator(onepassword.Account{BaseType: onepassword.BaseType{ID: "ACCOUNT2", Name: "Subsidiary"}, Domain: "subsidiary"}).
+		AddGroup(onepassword.Group{BaseType: onepassword.BaseType{ID: "G2", Name: "Finance"}, Type: "USER_DEFINED", State: "ACTIVE"})
+	g := groupBuilder(newAccounts(onepassword.NewCli("service", "", newTestEmulator()), onepassword.NewCli(onepassword.AuthTypeSCIM, "", second)))
+
+	_, err := g.Delete(t.Context(), &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "G2"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.False(t, ran(second, "group delete"))
+}
+
+func TestGroupDeleteRefusesBuiltInGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		group string
+	}{
+		{"owners", `{"id":"G9","name":"Owners","state":"ACTIVE","type":"OWNERS"}`},
+		{"administrators", `{"id":"G9","name":"Administrators","state":"ACTIVE","type":"ADMINISTRATORS"}`},
+		{"recovery", `{"id":"G9","name":"Recovery","state":"ACTIVE","type":"RECOVERY"}`},
+		{"no type", `{"id":"G9","name":"Owners","state":"ACTIVE"}`},
+		{"inactive", `{"id":"G9","name":"Project Falcon","state":"INACTIVE","type":"USER_DEFINED"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := optest.NewScriptedRunner().
+				OnJSON([]string{"group", "get", "G9"}, tt.group).
+				OnJSON([]string{"account", "get"}, `{"id":"ACCOUNT1","name":"Example","type":"BUSINESS","state":"ACTIVE"}`)
+			g := groupBuilder(newAccounts(onepassword.NewCli("service", "", runner)))
+
+			_, err := g.Delete(t.Context(), &v2.ResourceId{ResourceType: resourceTypeGroup.Id, Resource: "G9"})
+			require.Equal(t, codes.FailedPrecondition, status.Code(err))
+			for _, call := range runner.Calls() {
+				require.NotEqual(t, "delete", call.Args[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+)
+
+// resolvePrincipal returns the 1Password ID to provision a user or group principal with.
+// Groups are named by their ID, users are resolved with resolveUser.
+func resolvePrincipal(ctx context.Context, cli *onepassword.OnePasswordClient, principal *v2.Resource) (string, error) {
+	switch principal.Id.ResourceType {
+	case resourceTypeGroup.Id:
+		return principal.Id.Resource, nil
+	case resourceTypeUser.Id:
+		user, err := resolveUser(ctx, cli, principal)
+		if err != nil {
+			return "", err
+		}
+		return user.ID, nil
+	default:
+		return "", fmt.Errorf("baton-1password: cannot provision a principal of type %s", principal.Id.ResourceType)
+	}
+}
+
+// resolveUser finds the user of a principal by its 1Password user ID, falling back to the email of its user trait,
+// so that users who share a name never get each other's access and renamed users can be provisioned before a resync.
+// More than one user with the email is an error rather than a guess.
+func resolveUser(ctx context.Context, cli *onepassword.OnePasswordClient, principal *v2.Resource) (onepassword.User, error) {
+	users, err := cli.ListUsers(ctx)
+	if err != nil {
+		return onepassword.User{}, wrapError(err, "failed listing users")
+	}
+
+	for _, user := range users {
+		if user.ID == principal.Id.Resource {
+			return user, nil
+		}
+	}
+
+	email := principalEmail(principal)
+	if email == "" {
+		return onepassword.User{}, uhttp.WrapErrors(codes.NotFound,
+			fmt.Sprintf("baton-1password: user %s is not in the account and has no email to find them by", principal.Id.Resource))
+	}
+
+	var matches []onepassword.User
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			matches = append(matches, user)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return onepassword.User{}, uhttp.WrapErrors(codes.NotFound,
+			fmt.Sprintf("baton-1password: user %s is not in the account, and neither is a user with the email %s", principal.Id.Resource, email))
+	case 1:
+		return matches[0], nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, user := range matches {
+			ids = append(ids, user.ID)
+		}
+		return onepassword.User{}, uhttp.WrapErrors(codes.FailedPrecondition,
+			fmt.Sprintf("baton-1password: user %s is not in the account and the email %s matches several users: %s",
+				principal.Id.Resource, email, strings.Join(ids, ", ")))
+	}
+}
+
+// principalEmail returns the primary email of a user resource, falling back to its first email.
+func principalEmail(principal *v2.Resource) string {
+	trait, err := resource.GetUserTrait(principal)
+	if err != nil {
+		return ""
+	}
+
+	emails := trait.GetEmails()
+	for _, email := range emails {
+		if email.GetIsPrimary() {
+			return email.GetAddress()
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].GetAddress()
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package connector
+
+import (
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResolveUser(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator().
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Alice Smith"}, Email: "alice.smith@example.com"}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U4", Name: "Dana"}, Email: "dana@example.com"}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U5", Name: "Dana"}, Email: "DANA@example.com"})
+	cli := onepassword.NewCli("service", "", emu)
+
+	principal := func(id, name, email string) *v2.Resource {
+		r, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: id, Name: name}, Email: email}, testAccountID)
+		require.NoError(t, err)
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		principal *v2.Resource
+		expected  string
+		code      codes.Code
+	}{
+		{"by ID despite a shared name", principal("U3", "Alice Smith", "alice.smith@example.com"), "U3", codes.OK},
+		{"renamed since the last sync", principal("U1", "Alice Jones", "alice@example.com"), "U1", codes.OK},
+		{"stale ID falls back to email", principal("U9", "Bob Jones", "Bob@Example.com"), "U2", codes.OK},
+		{"unknown ID and email", principal("U9", "Carol White", "carol@example.com"), "", codes.NotFound},
+		{"unknown ID without email", principal("U9", "Carol White", ""), "", codes.NotFound},
+		{"ambiguous email", principal("U9", "Dana", "dana@example.com"), "", codes.FailedPrecondition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := resolveUser(ctx, cli, tt.principal)
+			require.Equal(t, tt.code, status.Code(err), "%v", err)
+			require.Equal(t, tt.expected, user.ID)
+		})
+	}
+
+	_, err := resolveUser(ctx, cli, principal("U9", "Dana", "dana@example.com"))
+	require.ErrorContains(t, err, "matches several users: U4, U5")
+}
+
+func TestProvisioningUsesUserID(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator().
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Alice Smith"}, Email: "alice.smith@example.com"})
+	accounts := newAccounts(onepassword.NewCli("service", "", emu))
+
+	namesake, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Alice Smith"}, Email: "alice.smith@example.com"}, testAccountID)
+	require.NoError(t, err)
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+
+	_, err = groupBuilder(accounts).Grant(ctx, namesake, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "group user grant --group G1 --role member --user U3"))
+
+	_, err = vaultBuilder(accounts, nil, false).Grant(ctx, namesake, ent.NewPermissionEntitlement(vault, "view items"))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "vault user grant --vault V1 --user U3"))
+}
@@ -7,13 +7,17 @@ import (
 	onepassword "github.com/conductorone/baton-1password/pkg/client"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	resource "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type userResourceType struct {
 	resourceType *v2.ResourceType
-	cli          *onepassword.OnePasswordClient
+	accounts     *accounts
 }
 
 func (u *userResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -52,6 +56,7 @@ func userResource(user onepassword.User, parentResourceID *v2.ResourceId) (*v2.R
 
 	userTraitOptions := []resource.UserTraitOption{
 		resource.WithEmail(user.Email, true),
+		resource.WithStatus(userStatus),
 	}
 
 	ret, err := resource.NewUserResource(
@@ -71,15 +76,22 @@ func userResource(user onepassword.User, parentResourceID *v2.ResourceId) (*v2.R
 }
 
 func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "users")
+
 	if parentId == nil {
 		return nil, "", nil, nil
 	}
 
+	cli, err := u.accounts.forAccount(ctx, parentId.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	var rv []*v2.Resource
 
-	users, err := u.cli.ListUsers(ctx)
+	users, err := cli.ListUsers(ctx)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, wrapError(err, "failed listing users")
 	}
 
 	for _, user := range users {
@@ -102,9 +114,103 @@ func (u *userResourceType) Grants(ctx context.Context, _ *v2.Resource, _ *pagina
 	return nil, "", nil, nil
 }
 
-func userBuilder(cli *onepassword.OnePasswordClient) *userResourceType {
+// CreateAccount invites a user to 1Password by email with `op user provision`.
+// 1Password emails the invitation and handles enrolment, so no credential is returned. The user is pending until they accept it,
+// and can be granted groups and vaults right away.
+// With several accounts configured, the account_id field of the profile names the account to invite the user to.
+func (u *userResourceType) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	_ *v2.LocalCredentialOptions,
+) (connectorbuilder.CreateAccountResponse, []*v2.PlaintextData, annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	email := accountEmail(accountInfo)
+	if email == "" {
+		return nil, nil, nil, uhttp.WrapErrors(codes.InvalidArgument, "baton-1password: an email is required to create an account")
+	}
+
+	cli, accountID, err := u.accounts.forNewResource(ctx, profileString(accountInfo.GetProfile(), "account_id"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !cli.HasCLI() {
+		return nil, nil, nil, uhttp.WrapErrors(codes.FailedPrecondition, "baton-1password: creating accounts requires the op CLI")
+	}
+
+	users, err := cli.ListUsers(ctx)
+	if err != nil {
+		return nil, nil, nil, wrapError(err, "failed listing users")
+	}
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			ur, err := userResource(user, accountID)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return &v2.CreateAccountResponse_AlreadyExistsResult{Resource: ur, IsCreateAccountResult: true}, nil, nil, nil
+		}
+	}
+
+	user, err := cli.ProvisionUser(ctx, email, accountName(accountInfo, email))
+	if err != nil {
+		return nil, nil, nil, wrapError(err, "failed provisioning user %s", email)
+	}
+
+	ur, err := userResource(user, accountID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &v2.CreateAccountResponse_SuccessResult{Resource: ur, IsCreateAccountResult: true}, nil, nil, nil
+}
+
+func (u *userResourceType) CreateAccountCapabilityDetails(_ context.Context) (*v2.CredentialDetailsAccountProvisioning, annotations.Annotations, error) {
+	return &v2.CredentialDetailsAccountProvisioning{
+		SupportedCredentialOptions: []v2.CapabilityDetailCredentialOption{
+			v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+		},
+		PreferredCredentialOption: v2.CapabilityDetailCredentialOption_CAPABILITY_DETAIL_CREDENTIAL_OPTION_NO_PASSWORD,
+	}, nil, nil
+}
+
+// accountEmail returns the primary email of an account request, falling back to its first email and then to its login.
+func accountEmail(accountInfo *v2.AccountInfo) string {
+	emails := accountInfo.GetEmails()
+	for _, email := range emails {
+		if email.GetIsPrimary() {
+			return email.GetAddress()
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].GetAddress()
+	}
+	if login := accountInfo.GetLogin(); strings.Contains(login, "@") {
+		return login
+	}
+	return profileString(accountInfo.GetProfile(), "email")
+}
+
+// accountName returns the full name of an account request, from the name or the first_name and last_name fields of its profile.
+// 1Password requires a name, the email stands in for a missing one.
+func accountName(accountInfo *v2.AccountInfo, email string) string {
+	profile := accountInfo.GetProfile()
+	if name := profileString(profile, "name"); name != "" {
+		return name
+	}
+	if name := strings.TrimSpace(profileString(profile, "first_name") + " " + profileString(profile, "last_name")); name != "" {
+		return name
+	}
+	return email
+}
+
+func profileString(profile *structpb.Struct, key string) string {
+	return strings.TrimSpace(profile.GetFields()[key].GetStringValue())
+}
+
+func userBuilder(accounts *accounts) *userResourceType {
 	return &userResourceType{
 		resourceType: resourceTypeUser,
-		cli:          cli,
+		accounts:     accounts,
 	}
 }
@@ -0,0 +1,212 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	config "github.com/conductorone/baton-sdk/pb/c1/config/v1"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/actions"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	userActionSuspend    = "suspend"
+	userActionReactivate = "reactivate"
+	userActionConfirm    = "confirm"
+	userActionDelete     = "delete"
+
+	actionArgResourceID       = "resource_id"
+	actionArgDeauthorizeAfter = "deauthorize_devices_after"
+	actionReturnSuccess       = "success"
+	actionReturnResource      = "resource"
+)
+
+func userIDArgument(description string) *config.Field {
+	return &config.Field{
+		Name:        actionArgResourceID,
+		DisplayName: "User",
+		Description: description,
+		IsRequired:  true,
+		Field:       &config.Field_ResourceIdField{ResourceIdField: &config.ResourceIdField{}},
+	}
+}
+
+// userActionReturnTypes describe the results of user actions, the user as it is after the action.
+var userActionReturnTypes = []*config.Field{
+	{
+		Name:        actionReturnSuccess,
+		DisplayName: "Success",
+		Field:       &config.Field_BoolField{BoolField: &config.BoolField{}},
+	},
+	{
+		Name:        actionReturnResource,
+		DisplayName: "User",
+		Description: "The user after the action, absent once the user is deleted",
+		Field:       &config.Field_ResourceField{ResourceField: &config.ResourceField{}},
+	},
+}
+
+// ResourceActions registers the actions that change the state of a user.
+func (u *userResourceType) ResourceActions(ctx context.Context, registry actions.ActionRegistry) error {
+	userActions := []struct {
+		schema  *v2.BatonActionSchema
+		handler actions.ActionHandler
+	}{
+		{
+			schema: &v2.BatonActionSchema{
+				Name:        userActionSuspend,
+				DisplayName: "Suspend user",
+				Description: "Suspend a 1Password user, who can no longer sign in until reactivated",
+				Arguments: []*config.Field{
+					userIDArgument("The user to suspend"),
+					{
+						Name:        actionArgDeauthorizeAfter,
+						DisplayName: "Deauthorize devices after",
+						Description: "Deauthorize the devices of the user once this long has passed, such as 30m or 24h. Devices stay authorized when empty",
+						Field:       &config.Field_StringField{StringField: &config.StringField{}},
+					},
+				},
+				ReturnTypes: userActionReturnTypes,
+				ActionType:  []v2.ActionType{v2.ActionType_ACTION_TYPE_ACCOUNT_DISABLE},
+			},
+			handler: u.suspend,
+		},
+		{
+			schema: &v2.BatonActionSchema{
+				Name:        userActionReactivate,
+				DisplayName: "Reactivate user",
+				Description: "Reactivate a suspended 1Password user",
+				Arguments:   []*config.Field{userIDArgument("The user to reactivate")},
+				ReturnTypes: userActionReturnTypes,
+				ActionType:  []v2.ActionType{v2.ActionType_ACTION_TYPE_ACCOUNT_ENABLE},
+			},
+			handler: u.reactivate,
+		},
+		{
+			schema: &v2.BatonActionSchema{
+				Name:        userActionConfirm,
+				DisplayName: "Confirm user",
+				Description: "Confirm a 1Password user who has accepted their invitation",
+				Arguments:   []*config.Field{userIDArgument("The user to confirm")},
+				ReturnTypes: userActionReturnTypes,
+				ActionType:  []v2.ActionType{v2.ActionType_ACTION_TYPE_DYNAMIC},
+			},
+			handler: u.confirm,
+		},
+		{
+			schema: &v2.BatonActionSchema{
+				Name:        userActionDelete,
+				DisplayName: "Delete user",
+				Description: "Remove a user from the 1Password account, along with their group memberships and vault access",
+				Arguments:   []*config.Field{userIDArgument("The user to delete")},
+				ReturnTypes: userActionReturnTypes,
+				ActionType:  []v2.ActionType{v2.ActionType_ACTION_TYPE_RESOURCE_DELETE},
+			},
+			handler: u.delete,
+		},
+	}
+
+	for _, action := range userActions {
+		if err := registry.Register(ctx, action.schema, action.handler); err != nil {
+			return fmt.Errorf("baton-1password: failed registering user action %s: %w", action.schema.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (u *userResourceType) suspend(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	var deauthorizeAfter time.Duration
+	if after, ok := actions.GetStringArg(args, actionArgDeauthorizeAfter); ok && after != "" {
+		var err error
+		deauthorizeAfter, err = time.ParseDuration(after)
+		if err != nil || deauthorizeAfter <= 0 {
+			return nil, nil, uhttp.WrapErrors(codes.InvalidArgument,
+				fmt.Sprintf("baton-1password: %s must be a positive duration such as 30m or 24h, got %q", actionArgDeauthorizeAfter, after))
+		}
+	}
+
+	return u.userAction(ctx, args, func(ctx context.Context, cli *onepassword.OnePasswordClient, user onepassword.User) error {
+		if user.State == "SUSPENDED" {
+			return nil
+		}
+		return wrapError(cli.SuspendUser(ctx, user.ID, deauthorizeAfter), "failed suspending user %s", user.ID)
+	})
+}
+
+func (u *userResourceType) reactivate(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return u.userAction(ctx, args, func(ctx context.Context, cli *onepassword.OnePasswordClient, user onepassword.User) error {
+		if user.State == "ACTIVE" {
+			return nil
+		}
+		return wrapError(cli.ReactivateUser(ctx, user.ID), "failed reactivating user %s", user.ID)
+	})
+}
+
+func (u *userResourceType) confirm(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return u.userAction(ctx, args, func(ctx context.Context, cli *onepassword.OnePasswordClient, user onepassword.User) error {
+		if user.State == "ACTIVE" {
+			return nil
+		}
+		return wrapError(cli.ConfirmUser(ctx, user.ID), "failed confirming user %s", user.ID)
+	})
+}
+
+func (u *userResourceType) delete(ctx context.Context, args *structpb.Struct) (*structpb.Struct, annotations.Annotations, error) {
+	return u.userAction(ctx, args, func(ctx context.Context, cli *onepassword.OnePasswordClient, user onepassword.User) error {
+		return wrapError(cli.DeleteUser(ctx, user.ID), "failed deleting user %s", user.ID)
+	})
+}
+
+// userAction applies change to the user named by the resource_id argument, then reads the user again,
+// so the returned resource carries the new status. Users that are already in the requested state are left alone by change.
+func (u *userResourceType) userAction(
+	ctx context.Context,
+	args *structpb.Struct,
+	change func(ctx context.Context, cli *onepassword.OnePasswordClient, user onepassword.User) error,
+) (*structpb.Struct, annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	id, ok := actions.GetResourceIDArg(args, actionArgResourceID)
+	if !ok || id.ResourceType != resourceTypeUser.Id {
+		return nil, nil, uhttp.WrapErrors(codes.InvalidArgument, "baton-1password: a user resource_id is required")
+	}
+
+	cli, accountID, user, err := u.accounts.forUser(ctx, id.Resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cli.HasCLI() {
+		return nil, nil, uhttp.WrapErrors(codes.FailedPrecondition, "baton-1password: changing the state of users requires the op CLI")
+	}
+
+	if err := change(ctx, cli, user); err != nil {
+		return nil, nil, err
+	}
+
+	user, err = cli.GetUser(ctx, user.ID)
+	if errors.Is(err, onepassword.ErrNotFound) {
+		return actions.NewReturnValues(true), nil, nil
+	}
+	if err != nil {
+		return nil, nil, wrapError(err, "failed getting user %s", id.Resource)
+	}
+
+	ur, err := userResource(user, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	resourceField, err := actions.NewResourceReturnField(actionReturnResource, ur)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return actions.NewReturnValues(true, resourceField), nil, nil
+}
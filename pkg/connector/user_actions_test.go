@@ -0,0 +1,161 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/actions"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func userActionArgs(t *testing.T, userID string, extra map[string]any) *structpb.Struct {
+	t.Helper()
+
+	fields := map[string]any{
+		actionArgResourceID: map[string]any{"resource_type_id": resourceTypeUser.Id, "resource_id": userID},
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+
+	args, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return args
+}
+
+// returnedStatus returns the user trait status of the user resource returned by an action.
+func returnedStatus(t *testing.T, rv *structpb.Struct) v2.UserTrait_Status_Status {
+	t.Helper()
+
+	r, ok := actions.GetResourceFieldArg(rv, actionReturnResource)
+	require.True(t, ok, "no resource in %v", rv)
+	trait, err := resource.GetUserTrait(r)
+	require.NoError(t, err)
+	return trait.GetStatus().GetStatus()
+}
+
+func TestUserActionsRegistered(t *testing.T) {
+	ctx := context.Background()
+	manager := actions.NewActionManager(ctx)
+	registry, err := manager.GetTypeRegistry(ctx, resourceTypeUser.Id)
+	require.NoError(t, err)
+
+	u := userBuilder(newAccounts(onepassword.NewCli("service", "", newTestEmulator())))
+	require.NoError(t, u.ResourceActions(ctx, registry))
+
+	schemas, _, err := manager.ListActionSchemas(ctx, resourceTypeUser.Id)
+	require.NoError(t, err)
+	var names []string
+	for _, schema := range schemas {
+		names = append(names, schema.Name)
+	}
+	require.ElementsMatch(t, []string{userActionSuspend, userActionReactivate, userActionConfirm, userActionDelete}, names)
+}
+
+func TestUserActions(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	u := userBuilder(newAccounts(onepassword.NewCli("service", "", emu)))
+
+	rv, _, err := u.suspend(ctx, userActionArgs(t, "U1", map[string]any{actionArgDeauthorizeAfter: "30m"}))
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_Status_STATUS_DISABLED, returnedStatus(t, rv))
+	require.True(t, ran(emu, "user suspend U1 --deauthorize-devices-after 30m"))
+
+	// Suspending a suspended user changes nothing.
+	rv, _, err = u.suspend(ctx, userActionArgs(t, "U1", nil))
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_Status_STATUS_DISABLED, returnedStatus(t, rv))
+	suspensions := 0
+	for _, call := range emu.Calls() {
+		if call.Args[0] == "user" && call.Args[1] == "suspend" {
+			suspensions++
+		}
+	}
+	require.Equal(t, 1, suspensions)
+
+	rv, _, err = u.reactivate(ctx, userActionArgs(t, "U1", nil))
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_Status_STATUS_ENABLED, returnedStatus(t, rv))
+
+	_, _, err = u.suspend(ctx, userActionArgs(t, "U2", map[string]any{actionArgDeauthorizeAfter: "1440m"}))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "user suspend U2 --deauthorize-devices-after 24h"))
+
+	rv, _, err = u.delete(ctx, userActionArgs(t, "U2", nil))
+	require.NoError(t, err)
+	_, ok := actions.GetStructArg(rv, actionReturnResource)
+	require.False(t, ok)
+	success, _ := actions.GetBoolArg(rv, actionReturnSuccess)
+	require.True(t, success)
+
+	_, err = onepassword.NewCli("service", "", emu).GetUser(ctx, "U2")
+	require.ErrorIs(t, err, onepassword.ErrNotFound)
+}
+
+func TestUserActionsReadCurrentState(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(onepassword.DefaultCacheTTL))
+	u := userBuilder(newAccounts(cli))
+
+	// A sync caches Alice as active, and she is then suspended outside of the connector.
+	alice, err := cli.GetUser(ctx, "U1")
+	require.NoError(t, err)
+	require.Equal(t, "ACTIVE", alice.State)
+	require.NoError(t, onepassword.NewCli("service", "", emu).SuspendUser(ctx, "U1", 0))
+
+	rv, _, err := u.reactivate(ctx, userActionArgs(t, "U1", nil))
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_Status_STATUS_ENABLED, returnedStatus(t, rv))
+	require.True(t, ran(emu, "user reactivate U1"))
+}
+
+func TestUserActionsRequireTheCLIOfTheUserAccount(t *testing.T) {
+	ctx := t.Context()
+	// The second account is reached through a SCIM bridge alone.
+	second := optest.NewEmulator(onepassword.Account{BaseType: onepassword.BaseType{ID: "ACCOUNT2", Name: "Subsidiary"}, Domain: "subsidiary"}).
+		AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Carol White"}, Email: "carol@subsidiary.com"})
+	u := userBuilder(newAccounts(onepassword.NewCli("service", "", newTestEmulator()), onepassword.NewCli(onepassword.AuthTypeSCIM, "", second)))
+
+	_, _, err := u.suspend(ctx, userActionArgs(t, "U3", nil))
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.False(t, ran(second, "user suspend"))
+}
+
+func TestConfirmInvitedUser(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu)
+	u := userBuilder(newAccounts(cli))
+
+	invited, err := cli.ProvisionUser(ctx, "carol@example.com", "Carol White")
+	require.NoError(t, err)
+
+	rv, _, err := u.confirm(ctx, userActionArgs(t, invited.ID, nil))
+	require.NoError(t, err)
+	require.Equal(t, v2.UserTrait_Status_STATUS_ENABLED, returnedStatus(t, rv))
+}
+
+func TestUserActionErrors(t *testing.T) {
+	ctx := t.Context()
+	u := userBuilder(newAccounts(onepassword.NewCli("service", "", newTestEmulator())))
+
+	for _, after := range []string{"tomorrow", "0s", "-1h"} {
+		_, _, err := u.suspend(ctx, userActionArgs(t, "U1", map[string]any{actionArgDeauthorizeAfter: after}))
+		require.Equal(t, codes.InvalidArgument, status.Code(err), after)
+	}
+
+	_, _, err := u.reactivate(ctx, &structpb.Struct{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, _, err = u.delete(ctx, userActionArgs(t, "U9", nil))
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
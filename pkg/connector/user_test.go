@@ -0,0 +1,115 @@
+package connector
+
+import (
+	"testing"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newAccountInfo(t *testing.T, email string, profile map[string]any) *v2.AccountInfo {
+	t.Helper()
+
+	p, err := structpb.NewStruct(profile)
+	require.NoError(t, err)
+
+	return &v2.AccountInfo{
+		Emails:  []*v2.AccountInfo_Email{{Address: email, IsPrimary: true}},
+		Login:   email,
+		Profile: p,
+	}
+}
+
+func TestCreateAccount(t *testing.T) {
+	ctx := t.Context()
+	accounts := newAccounts(onepassword.NewCli("service", "", newTestEmulator()))
+	u := userBuilder(accounts)
+
+	res, plaintexts, _, err := u.CreateAccount(ctx,
+		newAccountInfo(t, "carol@example.com", map[string]any{"first_name": "Carol", "last_name": "White"}),
+		&v2.LocalCredentialOptions{},
+	)
+	require.NoError(t, err)
+	require.Empty(t, plaintexts)
+
+	created, ok := res.(*v2.CreateAccountResponse_SuccessResult)
+	require.True(t, ok, "unexpected result %T", res)
+	carol := created.Resource
+	require.Equal(t, "Carol White", carol.DisplayName)
+	require.Equal(t, testAccountID.Resource, carol.ParentResourceId.Resource)
+
+	users, _, _, err := u.List(ctx, testAccountID, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, users, 3)
+
+	// The invited user can be granted access before they accept the invitation.
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	_, err = groupBuilder(accounts).Grant(ctx, carol, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.NoError(t, err)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	_, err = vaultBuilder(accounts, nil, false).Grant(ctx, carol, ent.NewPermissionEntitlement(vault, "view items"))
+	require.NoError(t, err)
+
+	res, _, _, err = u.CreateAccount(ctx, newAccountInfo(t, "Carol@Example.com", nil), &v2.LocalCredentialOptions{})
+	require.NoError(t, err)
+	existing, ok := res.(*v2.CreateAccountResponse_AlreadyExistsResult)
+	require.True(t, ok, "unexpected result %T", res)
+	require.Equal(t, carol.Id.Resource, existing.Resource.Id.Resource)
+}
+
+func TestCreateAccountReadsCurrentUsers(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(onepassword.DefaultCacheTTL))
+	u := userBuilder(newAccounts(cli))
+
+	// A sync caches the users, and Carol is then invited outside of the connector.
+	users, _, _, err := u.List(ctx, testAccountID, &pagination.Token{})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	emu.AddUser(onepassword.User{BaseType: onepassword.BaseType{ID: "U3", Name: "Carol White"}, Email: "carol@example.com"})
+
+	res, _, _, err := u.CreateAccount(ctx, newAccountInfo(t, "carol@example.com", nil), &v2.LocalCredentialOptions{})
+	require.NoError(t, err)
+	existing, ok := res.(*v2.CreateAccountResponse_AlreadyExistsResult)
+	require.True(t, ok, "unexpected result %T", res)
+	require.Equal(t, "U3", existing.Resource.Id.Resource)
+	require.False(t, ran(emu, "user provision"))
+}
+
+func TestCreateAccountName(t *testing.T) {
+	for _, tc := range []struct {
+		profile  map[string]any
+		expected string
+	}{
+		{map[string]any{"name": "Dana Scully", "first_name": "Dana"}, "Dana Scully"},
+		{map[string]any{"first_name": "Dana"}, "Dana"},
+		{nil, "dana@example.com"},
+	} {
+		emu := newTestEmulator()
+		u := userBuilder(newAccounts(onepassword.NewCli("service", "", emu)))
+
+		res, _, _, err := u.CreateAccount(t.Context(), newAccountInfo(t, "dana@example.com", tc.profile), &v2.LocalCredentialOptions{})
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, res.(*v2.CreateAccountResponse_SuccessResult).Resource.DisplayName)
+
+		calls := emu.Calls()
+		require.Equal(t, []string{"user", "provision", "--email", "dana@example.com", "--name", tc.expected, "--format=json"}, calls[len(calls)-1].Args)
+	}
+}
+
+func TestCreateAccountRequiresEmail(t *testing.T) {
+	u := userBuilder(newAccounts(onepassword.NewCli("service", "", newTestEmulator())))
+
+	_, _, _, err := u.CreateAccount(t.Context(), &v2.AccountInfo{Login: "carol"}, &v2.LocalCredentialOptions{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-
+	"slices"
 	"strings"
 
 	onepassword "github.com/conductorone/baton-1password/pkg/client"
@@ -14,9 +14,12 @@ import (
 	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
 	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func AllVaultPermissions() mapset.Set[string] {
@@ -126,8 +129,9 @@ const businessAccountType = "BUSINESS"
 
 type vaultResourceType struct {
 	resourceType          *v2.ResourceType
-	cli                   *onepassword.OnePasswordClient
+	accounts              *accounts
 	limitVaultPermissions mapset.Set[string]
+	forceDelete           bool
 }
 
 func (g *vaultResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -150,17 +154,25 @@ func vaultResource(vault onepassword.Vault, parentResourceID *v2.ResourceId) (*v
 }
 
 func (g *vaultResourceType) List(ctx context.Context, parentId *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "vaults")
+
 	if parentId == nil {
 		return nil, "", nil, nil
 	}
 
+	cli, err := g.accounts.forAccount(ctx, parentId.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	var rv []*v2.Resource
 
-	vaults, err := g.cli.ListVaults(ctx)
+	vaults, err := cli.ListVaults(ctx)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, wrapError(err, "failed listing vaults")
 	}
 
+	vaultIDs := make([]string, 0, len(vaults))
 	for _, vault := range vaults {
 		vaultCopy := vault
 		gr, err := vaultResource(vaultCopy, parentId)
@@ -168,20 +180,30 @@ func (g *vaultResourceType) List(ctx context.Context, parentId *v2.ResourceId, _
 			return nil, "", nil, err
 		}
 		rv = append(rv, gr)
+		vaultIDs = append(vaultIDs, vault.ID)
 	}
 
+	cli.PrefetchVaultAccess(onepassword.WithBudgetPhase(ctx, "vault grants"), vaultIDs)
+
 	return rv, "", nil, nil
 }
 
 func (g *vaultResourceType) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	var rv []*v2.Entitlement
+	ctx = onepassword.WithBudgetPhase(ctx, "vault entitlements")
 
-	account, err := g.cli.GetAccount(ctx)
+	cli, err := g.accounts.forResource(ctx, resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	memberOptions := PopulateOptions(resource.DisplayName, memberEntitlement, resource.Id.ResourceType)
+	var rv []*v2.Entitlement
+
+	account, err := cli.GetAccount(ctx)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "failed getting account")
+	}
+
+	memberOptions := vaultEntitlementOptions(resource, memberEntitlement)
 	membetEnt := ent.NewAssignmentEntitlement(resource, memberEntitlement, memberOptions...)
 	if g.limitVaultPermissions != nil {
 		if g.limitVaultPermissions.Contains(memberEntitlement) {
@@ -199,7 +221,7 @@ func (g *vaultResourceType) Entitlements(ctx context.Context, resource *v2.Resou
 					continue
 				}
 			}
-			businessOptions := PopulateOptions(resource.DisplayName, permission, resource.Id.ResourceType)
+			businessOptions := vaultEntitlementOptions(resource, permission)
 			businessEntitlement := ent.NewPermissionEntitlement(resource, permission, businessOptions...)
 			rv = append(rv, businessEntitlement)
 		}
@@ -210,7 +232,7 @@ func (g *vaultResourceType) Entitlements(ctx context.Context, resource *v2.Resou
 					continue
 				}
 			}
-			basicOptions := PopulateOptions(resource.DisplayName, permission, resource.Id.ResourceType)
+			basicOptions := vaultEntitlementOptions(resource, permission)
 			basicEntitlement := ent.NewPermissionEntitlement(resource, permission, basicOptions...)
 			rv = append(rv, basicEntitlement)
 		}
@@ -219,12 +241,19 @@ func (g *vaultResourceType) Entitlements(ctx context.Context, resource *v2.Resou
 	return rv, "", nil, nil
 }
 
+// vaultEntitlementOptions returns the options of a vault entitlement, which can be granted to groups as well as users.
+func vaultEntitlementOptions(resource *v2.Resource, permission string) []ent.EntitlementOption {
+	return append(PopulateOptions(resource.DisplayName, permission, resource.Id.ResourceType), ent.WithGrantableTo(resourceTypeUser, resourceTypeGroup))
+}
+
 const (
 	vaultListUsersOp  = "vault-list-users"
 	vaultListGroupsOp = "vault-list-groups"
 )
 
 func (g *vaultResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "vault grants")
+
 	var rv []*v2.Grant
 	bag := &pagination.Bag{}
 	err := bag.Unmarshal(pToken.Token)
@@ -242,27 +271,36 @@ func (g *vaultResourceType) Grants(ctx context.Context, resource *v2.Resource, p
 		})
 	}
 
-	account, err := g.cli.GetAccount(ctx)
+	cli, err := g.accounts.forResource(ctx, resource)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
+	account, err := cli.GetAccount(ctx)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "failed getting account")
+	}
+
 	switch bag.Current().ResourceTypeID {
 	case vaultListUsersOp:
 		bag.Pop()
-		vaultMembers, err := g.cli.ListVaultMembers(ctx, resource.Id.Resource)
+		users, err := vaultAccessByUser(ctx, cli, resource.Id.Resource)
 		if err != nil {
 			return nil, "", nil, err
 		}
 
-		for _, member := range vaultMembers {
-			memberCopy := member
-			ur, err := userResource(memberCopy, resource.Id)
+		for _, access := range users {
+			// Access that only comes through groups is left to the expansion of the group grants.
+			if access.direct.Cardinality() == 0 {
+				continue
+			}
+
+			ur, err := userResource(access.user, resource.Id)
 			if err != nil {
 				return nil, "", nil, err
 			}
 
-			membershipGrant := grant.NewGrant(resource, memberEntitlement, ur.Id)
+			membershipGrant := grant.NewGrant(resource, memberEntitlement, ur.Id, access.grantOptions(memberEntitlement)...)
 			if g.limitVaultPermissions != nil {
 				if g.limitVaultPermissions.Contains(memberEntitlement) {
 					rv = append(rv, membershipGrant)
@@ -271,7 +309,7 @@ func (g *vaultResourceType) Grants(ctx context.Context, resource *v2.Resource, p
 				rv = append(rv, membershipGrant)
 			}
 
-			for _, permission := range member.Permissions {
+			for _, permission := range access.user.Permissions {
 				if g.limitVaultPermissions != nil {
 					if !g.limitVaultPermissions.Contains(permission) {
 						continue
@@ -280,18 +318,18 @@ func (g *vaultResourceType) Grants(ctx context.Context, resource *v2.Resource, p
 
 				var userPermissionGrant *v2.Grant
 				if account.Type == businessAccountType {
-					userPermissionGrant = grant.NewGrant(resource, businessPermissions[permission], ur.Id)
+					userPermissionGrant = grant.NewGrant(resource, businessPermissions[permission], ur.Id, access.grantOptions(permission)...)
 				} else {
-					userPermissionGrant = grant.NewGrant(resource, basicPermissions[permission], ur.Id)
+					userPermissionGrant = grant.NewGrant(resource, basicPermissions[permission], ur.Id, access.grantOptions(permission)...)
 				}
 				rv = append(rv, userPermissionGrant)
 			}
 		}
 	case vaultListGroupsOp:
 		bag.Pop()
-		vaultGroups, err := g.cli.ListVaultGroups(ctx, resource.Id.Resource)
+		vaultGroups, err := cli.ListVaultGroups(ctx, resource.Id.Resource)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", nil, wrapError(err, "failed listing groups of vault %s", resource.Id.Resource)
 		}
 
 		for _, group := range vaultGroups {
@@ -358,12 +396,17 @@ func (g *vaultResourceType) Grants(ctx context.Context, resource *v2.Resource, p
 	return rv, npt, nil, nil
 }
 
-// Grant a user access to a vault.
-// grants to vaults must be granted and revoked from individual users only when using just-in-time provisioning.
-// See Revoke limitations for more details.
+// Grant a user or a group access to a vault.
+// Only the permissions the principal does not hold yet are granted. Permissions a user inherits through groups are granted directly,
+// so that their access no longer depends on those groups.
 // If the connector is used through a service account, it can only grant or revoke permissions on those stores that have been created from that service account, otherwise it will return an error.
 func (g *vaultResourceType) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
-	username := principal.DisplayName
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
+		return nil, fmt.Errorf("baton-1password: only users or groups can be granted vault access")
+	}
+
 	vaultId := entitlement.Resource.Id.Resource
 
 	permissionGrant, err := extractRoleFromEntitlementID(entitlement.Id)
@@ -371,69 +414,195 @@ func (g *vaultResourceType) Grant(ctx context.Context, principal *v2.Resource, e
 		return nil, fmt.Errorf("could not extract role: %w", err)
 	}
 
-	account, err := g.cli.GetAccount(ctx)
+	cli, err := g.accounts.forGrant(ctx, principal, entitlement.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := cli.GetAccount(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch account: %w", err)
+		return nil, wrapError(err, "could not fetch account")
 	}
 
 	permissionsList := getPermissionsForGrantRevoke(permissionGrant, account.Type, false)
 
-	permissions := strings.Join(permissionsList, ",")
+	accessor, err := resolvePrincipal(ctx, cli, principal)
+	if err != nil {
+		return nil, err
+	}
 
-	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
-		return nil, fmt.Errorf("baton-1password: only users or groups can be granted vault access")
+	held, _, err := heldVaultPermissions(ctx, cli, vaultId, principal.Id.ResourceType, accessor)
+	if err != nil {
+		return nil, err
+	}
+	missing := slices.DeleteFunc(slices.Clone(permissionsList), func(p string) bool { return held.Contains(p) })
+	if len(missing) == 0 {
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
 	}
 
-	err = g.cli.AddUserToVault(ctx, vaultId, username, permissions)
+	permissions := strings.Join(missing, ",")
+
+	if principal.Id.ResourceType == resourceTypeGroup.Id {
+		err = cli.AddGroupToVault(ctx, vaultId, accessor, permissions)
+	} else {
+		err = cli.AddUserToVault(ctx, vaultId, accessor, permissions)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("baton-1password: failed granting to vault access: %w", err)
+		return nil, wrapError(err, "failed granting vault access")
 	}
 
 	return nil, nil
 }
 
-// Revoke a user's access to a vault.
-// This will error out if the principal's grant was inherited via a group membership with permissions to the vault.
-// 1Password CLI errors with "the accessor doesn't have any permissions" if the grant is inherited from a group.
-// Avoid mixing group and individual grants to vaults when using just-in-time provisioning.
+// Revoke a user's or a group's access to a vault.
+// Only the permissions the principal holds are revoked. For a user, that is the direct part of their access, access inherited through groups stays.
+// A grant that comes from groups alone is refused with an error naming the group memberships to remove instead.
 // If the connector is used through a service account, it can only grant or revoke permissions on those stores that have been created from that service account, otherwise it will return an error.
 func (g *vaultResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
 	entitlement := grant.Entitlement
+	principal := grant.Principal
+
+	if principal.Id.ResourceType != resourceTypeUser.Id && principal.Id.ResourceType != resourceTypeGroup.Id {
+		return nil, errors.New("baton-1password: only users or groups can have vault access revoked")
+	}
 
 	permissionGrant, err := extractRoleFromEntitlementID(entitlement.Id)
 	if err != nil {
 		return nil, fmt.Errorf("could not extract role: %w", err)
 	}
 
-	account, err := g.cli.GetAccount(ctx)
+	cli, err := g.accounts.forGrant(ctx, principal, entitlement.Resource)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch account: %w", err)
+		return nil, err
 	}
 
-	permissionsList := getPermissionsForGrantRevoke(permissionGrant, account.Type, true)
+	account, err := cli.GetAccount(ctx)
+	if err != nil {
+		return nil, wrapError(err, "could not fetch account")
+	}
 
-	permissions := strings.Join(permissionsList, ",")
+	permissionsList := getPermissionsForGrantRevoke(permissionGrant, account.Type, true)
 
-	principal := grant.Principal
-	username := principal.DisplayName
 	vaultId := entitlement.Resource.Id.Resource
 
-	if principal.Id.ResourceType != resourceTypeUser.Id {
-		return nil, errors.New("baton-1password: only users can have vault access revoked")
+	accessor, err := resolvePrincipal(ctx, cli, principal)
+	if err != nil {
+		return nil, err
 	}
 
-	err = g.cli.RemoveUserFromVault(ctx, vaultId, username, permissions)
+	held, access, err := heldVaultPermissions(ctx, cli, vaultId, principal.Id.ResourceType, accessor)
 	if err != nil {
-		return nil, fmt.Errorf("baton-1password: failed removing user from vault: %w", err)
+		return nil, err
+	}
+	revoked := slices.DeleteFunc(slices.Clone(permissionsList), func(p string) bool { return !held.Contains(p) })
+
+	if access != nil {
+		source, groups := access.source(permissionGrant)
+		if source == grantSourceGroup {
+			return nil, wrapError(onepassword.ErrInheritedGrant,
+				"%s of user %s on vault %s is only inherited through membership in %s, remove those group memberships instead",
+				strings.ReplaceAll(permissionGrant, "_", " "), accessor, vaultId, groupList(groups))
+		}
+		// Permissions implied by the revoke, such as those depending on the revoked one, may be inherited as well.
+		inherited := slices.DeleteFunc(slices.Clone(permissionsList), func(p string) bool {
+			source, _ := access.source(p)
+			return source == "" || source == grantSourceDirect
+		})
+		if len(revoked) > 0 && len(inherited) > 0 {
+			ctxzap.Extract(ctx).Info("revoking direct vault access, access inherited through groups stays",
+				zap.String("vault", vaultId), zap.String("user", accessor), zap.Strings("revoked", revoked), zap.Strings("inherited", inherited))
+		}
+	}
+	if len(revoked) == 0 {
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	permissions := strings.Join(revoked, ",")
+
+	if principal.Id.ResourceType == resourceTypeGroup.Id {
+		err = cli.RemoveGroupFromVault(ctx, vaultId, accessor, permissions)
+		if err != nil {
+			return nil, wrapError(err, "failed removing group from vault")
+		}
+		return nil, nil
+	}
+
+	err = cli.RemoveUserFromVault(ctx, vaultId, accessor, permissions)
+	if err != nil {
+		return nil, wrapError(err, "failed removing user from vault")
+	}
+
+	return nil, nil
+}
+
+// Create creates a vault with the display name and description of resource, in the account of its parent.
+// A google.protobuf.Struct annotation on resource may set the icon of the vault, and allow_admins_to_manage, which defaults to true.
+// A vault created by a service account can be granted and revoked by that service account.
+func (g *vaultResourceType) Create(ctx context.Context, resource *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	ctx = onepassword.WithBudgetPhase(ctx, "provisioning")
+
+	name := resource.GetDisplayName()
+	if name == "" {
+		return nil, nil, uhttp.WrapErrors(codes.InvalidArgument, "baton-1password: a name is required to create a vault")
+	}
+
+	settings := &structpb.Struct{}
+	annos := annotations.Annotations(resource.GetAnnotations())
+	if _, err := annos.Pick(settings); err != nil {
+		return nil, nil, uhttp.WrapErrors(codes.InvalidArgument, fmt.Sprintf("baton-1password: invalid vault settings: %s", err))
+	}
+	icon := settings.GetFields()["icon"].GetStringValue()
+	allowAdminsToManage := true
+	if v, ok := settings.GetFields()["allow_admins_to_manage"]; ok {
+		allowAdminsToManage = v.GetBoolValue()
+	}
+
+	cli, accountID, err := g.accounts.forNewResource(ctx, resource.GetParentResourceId().GetResource())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vault, err := cli.CreateVault(ctx, name, resource.GetDescription(), icon, allowAdminsToManage)
+	if err != nil {
+		return nil, nil, wrapError(err, "failed creating vault %s", name)
+	}
+
+	vr, err := vaultResource(vault, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return vr, nil, nil
+}
+
+// Delete deletes a vault. Vaults that hold items are only deleted with forceDelete, since their items go with them.
+func (g *vaultResourceType) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	ctx = onepassword.WithFreshReads(onepassword.WithBudgetPhase(ctx, "provisioning"))
+
+	cli, _, vault, err := g.accounts.forVault(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if vault.Items > 0 && !g.forceDelete {
+		return nil, uhttp.WrapErrors(codes.FailedPrecondition,
+			fmt.Sprintf("baton-1password: vault %s (%s) holds %d items and is only deleted with force-vault-deletion", vault.Name, vault.ID, vault.Items))
+	}
+
+	if err := cli.DeleteVault(ctx, vault.ID); err != nil {
+		return nil, wrapError(err, "failed deleting vault %s", vault.ID)
 	}
 
 	return nil, nil
 }
 
-func vaultBuilder(cli *onepassword.OnePasswordClient, limitVaultPermissions mapset.Set[string]) *vaultResourceType {
+func vaultBuilder(accounts *accounts, limitVaultPermissions mapset.Set[string], forceDelete bool) *vaultResourceType {
 	return &vaultResourceType{
 		resourceType:          resourceTypeVault,
-		cli:                   cli,
+		accounts:              accounts,
 		limitVaultPermissions: limitVaultPermissions,
+		forceDelete:           forceDelete,
 	}
 }
@@ -0,0 +1,186 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// Where the access of a user to a vault comes from, as recorded in the metadata of user vault grants.
+const (
+	grantSourceDirect         = "direct"
+	grantSourceGroup          = "group"
+	grantSourceDirectAndGroup = "direct_and_group"
+)
+
+// userVaultAccess is the access of a user to a vault, along with where each permission comes from.
+// op vault user list only reports the users granted access to the vault itself, with the permissions granted to them,
+// while op vault group list reports the access of each group, which its members inherit.
+// A permission that is granted both directly and through a group is therefore known to be both.
+type userVaultAccess struct {
+	// user is the user as op vault user list reports them, with their direct permissions, if they have any.
+	user   onepassword.User
+	direct mapset.Set[string]
+	// groups maps each inherited permission to the groups of the user that grant it.
+	groups map[string][]onepassword.Group
+}
+
+// source returns where a permission of the user comes from, and the groups that grant it.
+// memberEntitlement stands for any access to the vault. The source is empty when the user does not hold the permission.
+func (a *userVaultAccess) source(permission string) (string, []onepassword.Group) {
+	var direct bool
+	var groups []onepassword.Group
+	if permission == memberEntitlement {
+		direct = a.direct.Cardinality() > 0
+		for _, granting := range a.groups {
+			for _, group := range granting {
+				if !slices.ContainsFunc(groups, func(g onepassword.Group) bool { return g.ID == group.ID }) {
+					groups = append(groups, group)
+				}
+			}
+		}
+		slices.SortFunc(groups, func(a, b onepassword.Group) int { return strings.Compare(a.Name, b.Name) })
+	} else {
+		direct = a.direct.Contains(permission)
+		groups = a.groups[permission]
+	}
+
+	switch {
+	case direct && len(groups) > 0:
+		return grantSourceDirectAndGroup, groups
+	case direct:
+		return grantSourceDirect, nil
+	case len(groups) > 0:
+		return grantSourceGroup, groups
+	default:
+		return "", nil
+	}
+}
+
+// grantOptions records in the metadata of a user vault grant where the permission comes from, naming the groups it is inherited from.
+func (a *userVaultAccess) grantOptions(permission string) []grant.GrantOption {
+	source, groups := a.source(permission)
+	if source == "" {
+		return nil
+	}
+
+	metadata := map[string]interface{}{"source": source}
+	if len(groups) > 0 {
+		names := make([]interface{}, 0, len(groups))
+		for _, group := range groups {
+			names = append(names, group.Name)
+		}
+		metadata["groups"] = names
+	}
+
+	return []grant.GrantOption{grant.WithGrantMetadata(metadata)}
+}
+
+// vaultAccessByUser returns the access of every user to a vault, whether granted directly or through groups.
+// Users with direct access come first, in the order op lists them. Access through groups is only known when op can list the groups of a vault.
+func vaultAccessByUser(ctx context.Context, cli *onepassword.OnePasswordClient, vaultID string) ([]*userVaultAccess, error) {
+	members, err := cli.ListVaultMembers(ctx, vaultID)
+	if err != nil {
+		return nil, wrapError(err, "failed listing members of vault %s", vaultID)
+	}
+
+	var rv []*userVaultAccess
+	byID := make(map[string]*userVaultAccess, len(members))
+	for _, member := range members {
+		access := &userVaultAccess{
+			user:   member,
+			direct: mapset.NewSet(member.Permissions...),
+			groups: make(map[string][]onepassword.Group),
+		}
+		rv = append(rv, access)
+		byID[member.ID] = access
+	}
+
+	if !cli.Supports(onepassword.CapabilityVaultGroups) {
+		return rv, nil
+	}
+
+	vaultGroups, err := cli.ListVaultGroups(ctx, vaultID)
+	if err != nil {
+		return nil, wrapError(err, "failed listing groups of vault %s", vaultID)
+	}
+
+	for _, group := range vaultGroups {
+		if len(group.Permissions) == 0 {
+			continue
+		}
+
+		groupMembers, err := cli.ListGroupMembers(ctx, group.ID)
+		if err != nil {
+			return nil, wrapError(err, "failed listing members of group %s", group.ID)
+		}
+
+		for _, member := range groupMembers {
+			access, ok := byID[member.ID]
+			if !ok {
+				member.Permissions = nil
+				access = &userVaultAccess{
+					user:   member,
+					direct: mapset.NewSet[string](),
+					groups: make(map[string][]onepassword.Group),
+				}
+				rv = append(rv, access)
+				byID[member.ID] = access
+			}
+			for _, permission := range group.Permissions {
+				access.groups[permission] = append(access.groups[permission], group)
+			}
+		}
+	}
+
+	return rv, nil
+}
+
+// groupList names groups for error messages, such as "Engineering (G1), Design (G2)".
+func groupList(groups []onepassword.Group) string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, fmt.Sprintf("%s (%s)", group.Name, group.ID))
+	}
+	return strings.Join(names, ", ")
+}
+
+// heldVaultPermissions returns the permissions an accessor, a user or a group, is granted on a vault directly.
+// For a user, it also returns where their access comes from, since they may inherit further permissions through groups.
+func heldVaultPermissions(
+	ctx context.Context,
+	cli *onepassword.OnePasswordClient,
+	vaultID string,
+	resourceType string,
+	accessor string,
+) (mapset.Set[string], *userVaultAccess, error) {
+	if resourceType == resourceTypeGroup.Id {
+		groups, err := cli.ListVaultGroups(ctx, vaultID)
+		if err != nil {
+			return nil, nil, wrapError(err, "failed listing groups of vault %s", vaultID)
+		}
+		for _, group := range groups {
+			if group.ID == accessor {
+				return mapset.NewSet(group.Permissions...), nil, nil
+			}
+		}
+		return mapset.NewSet[string](), nil, nil
+	}
+
+	users, err := vaultAccessByUser(ctx, cli, vaultID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, access := range users {
+		if access.user.ID == accessor {
+			return access.direct, access, nil
+		}
+	}
+
+	return mapset.NewSet[string](), nil, nil
+}
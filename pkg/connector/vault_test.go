@@ -1,9 +1,23 @@
 package connector
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"testing"
 
+	onepassword "github.com/conductorone/baton-1password/pkg/client"
+	"github.com/conductorone/baton-1password/pkg/client/optest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestResolveDeps(t *testing.T) {
@@ -23,3 +37,426 @@ func TestResolveDeps(t *testing.T) {
 	actual = resolveDeps("", dependencyMap, make(map[string]bool))
 	require.Equal(t, expected, actual)
 }
+
+func TestVaultEntitlementsGrantableToGroups(t *testing.T) {
+	ctx := t.Context()
+	accounts := newAccounts(onepassword.NewCli("service", "", newTestEmulator()))
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	entitlements, _, _, err := vaultBuilder(accounts, nil, false).Entitlements(ctx, vault, &pagination.Token{})
+	require.NoError(t, err)
+	require.NotEmpty(t, entitlements)
+	for _, e := range entitlements {
+		require.ElementsMatch(t, []string{resourceTypeUser.Id, resourceTypeGroup.Id}, grantableTo(e), e.Id)
+	}
+
+	// Groups cannot be nested, so group entitlements stay grantable to users only.
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	entitlements, _, _, err = groupBuilder(accounts).Entitlements(ctx, group, &pagination.Token{})
+	require.NoError(t, err)
+	require.NotEmpty(t, entitlements)
+	for _, e := range entitlements {
+		require.Equal(t, []string{resourceTypeUser.Id}, grantableTo(e), e.Id)
+	}
+}
+
+func grantableTo(e *v2.Entitlement) []string {
+	var ids []string
+	for _, rt := range e.GetGrantableTo() {
+		ids = append(ids, rt.GetId())
+	}
+	return ids
+}
+
+func TestVaultGrants(t *testing.T) {
+	tests := []struct {
+		name     string
+		account  string
+		limit    []string
+		expected []string
+	}{
+		{
+			name:    "business",
+			account: `{"id":"ACCOUNT1","name":"Example","type":"BUSINESS"}`,
+			expected: []string{
+				"vault:V1:member:group:G1",
+				"vault:V1:view items:group:G1",
+				"vault:V1:member:user:U1",
+				"vault:V1:view items:user:U1",
+				"vault:V1:create items:user:U1",
+			},
+		},
+		{
+			name:    "limited permissions",
+			account: `{"id":"ACCOUNT1","name":"Example","type":"BUSINESS"}`,
+			limit:   []string{"create_items"},
+			expected: []string{
+				"vault:V1:create items:user:U1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := optest.NewScriptedRunner().
+				OnJSON([]string{"account", "get"}, tt.account).
+				OnJSON([]string{"vault", "group", "list", "V1"}, `[{"id":"G1","name":"Engineering","permissions":["view_items"]}]`).
+				OnJSON([]string{"group", "user", "list", "G1"}, `[]`).
+				OnJSON([]string{"vault", "user", "list", "V1"}, `[{"id":"U1","name":"Alice","email":"alice@example.com","permissions":["view_items","create_items"]}]`)
+
+			var limit mapset.Set[string]
+			if tt.limit != nil {
+				limit = mapset.NewSet(tt.limit...)
+			}
+			v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", runner)), limit, false)
+
+			vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+			require.NoError(t, err)
+
+			var actual []string
+			token := &pagination.Token{}
+			for {
+				grants, next, _, err := v.Grants(context.Background(), vault, token)
+				require.NoError(t, err)
+				actual = append(actual, grantIDs(grants)...)
+				if next == "" {
+					break
+				}
+				token = &pagination.Token{Token: next}
+			}
+
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+// vaultGrants returns the grants of a vault, across pages.
+func vaultGrants(t *testing.T, v *vaultResourceType, vault *v2.Resource) []*v2.Grant {
+	t.Helper()
+
+	var rv []*v2.Grant
+	token := &pagination.Token{}
+	for {
+		page, next, _, err := v.Grants(t.Context(), vault, token)
+		require.NoError(t, err)
+		rv = append(rv, page...)
+		if next == "" {
+			return rv
+		}
+		token = &pagination.Token{Token: next}
+	}
+}
+
+// grantSources maps the IDs of user grants to the source recorded in their metadata, and the groups it names.
+func grantSources(t *testing.T, grants []*v2.Grant) map[string]string {
+	t.Helper()
+
+	rv := make(map[string]string)
+	for _, g := range grants {
+		if g.Principal.Id.ResourceType != resourceTypeUser.Id {
+			continue
+		}
+		md := &v2.GrantMetadata{}
+		annos := annotations.Annotations(g.Annotations)
+		ok, err := annos.Pick(md)
+		require.NoError(t, err)
+		require.True(t, ok, "no metadata on %s", g.Id)
+
+		fields := md.GetMetadata().AsMap()
+		source := fields["source"].(string)
+		if groups, ok := fields["groups"].([]any); ok {
+			for _, group := range groups {
+				source += " " + group.(string)
+			}
+		}
+		rv[g.Id] = source
+	}
+	return rv
+}
+
+func TestVaultGrantSources(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+
+	// Bob only reaches the vault through Engineering, which the expansion of the group grant covers.
+	v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", emu)), nil, false)
+	require.Equal(t, map[string]string{
+		"vault:V1:member:user:U1":     "direct",
+		"vault:V1:view items:user:U1": "direct",
+	}, grantSources(t, vaultGrants(t, v, vault)))
+
+	emu.SetVaultUserPermissions("V1", "U2", "view_items", "view_and_copy_passwords", "edit_items")
+	v = vaultBuilder(newAccounts(onepassword.NewCli("service", "", emu)), nil, false)
+	require.Equal(t, map[string]string{
+		"vault:V1:member:user:U1":     "direct",
+		"vault:V1:view items:user:U1": "direct",
+		// Bob holds edit items directly, and so view items along with it, next to what Engineering grants.
+		// Create items only comes from Engineering, so it is left to the group grant.
+		"vault:V1:member:user:U2":                  "direct_and_group Engineering",
+		"vault:V1:view items:user:U2":              "direct_and_group Engineering",
+		"vault:V1:view and copy passwords:user:U2": "direct",
+		"vault:V1:edit items:user:U2":              "direct",
+	}, grantSources(t, vaultGrants(t, v, vault)))
+
+	bob, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}}, testAccountID)
+	require.NoError(t, err)
+
+	_, err = v.Revoke(ctx, grant.NewGrant(vault, "create items", bob))
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.ErrorIs(t, err, onepassword.ErrInheritedGrant)
+	require.ErrorContains(t, err, "create items of user U2 on vault V1 is only inherited through membership in Engineering (G1)")
+	require.False(t, ran(emu, "vault user revoke"))
+
+	// Revoking view items takes the permissions depending on it along, create items among them,
+	// but only Bob's direct permissions are revoked.
+	_, err = v.Revoke(ctx, grant.NewGrant(vault, "view items", bob))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "vault user revoke --vault V1 --user U2"))
+
+	members, err := onepassword.NewCli("service", "", emu).ListVaultMembers(ctx, "V1")
+	require.NoError(t, err)
+	require.False(t, slices.ContainsFunc(members, func(u onepassword.User) bool { return u.ID == "U2" }))
+
+	// Nothing is left of Bob's direct access.
+	_, err = v.Revoke(ctx, grant.NewGrant(vault, memberEntitlement, bob))
+	require.ErrorIs(t, err, onepassword.ErrInheritedGrant)
+}
+
+func TestVaultGrant(t *testing.T) {
+	runner := optest.NewScriptedRunner().
+		OnJSON([]string{"account", "get"}, `{"id":"ACCOUNT1","name":"Example","type":"BUSINESS"}`).
+		OnJSON([]string{"user", "list"}, `[{"id":"U1","name":"Alice","email":"alice@example.com","state":"ACTIVE"}]`).
+		OnJSON([]string{"vault", "user", "list", "V1"}, `[]`).
+		OnJSON([]string{"vault", "group", "list", "V1"}, `[]`).
+		OnJSON([]string{"vault", "user", "grant"}, "")
+	v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", runner)), nil, false)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	user, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice"}}, testAccountID)
+	require.NoError(t, err)
+
+	_, err = v.Grant(context.Background(), user, ent.NewPermissionEntitlement(vault, "create items"))
+	require.NoError(t, err)
+
+	calls := runner.Calls()
+	require.Len(t, calls, 5)
+	require.Equal(t, []string{
+		"vault", "user", "grant", "--vault", "V1", "--user", "U1", "--permissions", "view_items,create_items", "--format=json",
+	}, calls[4].Args)
+}
+
+func TestVaultGrantAndRevokeAreIdempotent(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", emu)), nil, false)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	alice, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}}, testAccountID)
+	require.NoError(t, err)
+	engineering, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+
+	annos, err := v.Grant(ctx, alice, ent.NewPermissionEntitlement(vault, "view items"))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyExists{}), annos)
+	require.False(t, ran(emu, "vault user grant"))
+
+	// Alice already holds view items, so only create items is granted.
+	annos, err = v.Grant(ctx, alice, ent.NewPermissionEntitlement(vault, "create items"))
+	require.NoError(t, err)
+	require.Nil(t, annos)
+	require.True(t, ran(emu, "vault user grant --vault V1 --user U1 --permissions create_items"))
+
+	// Of create items and import items, which depends on it, Alice only holds create items.
+	annos, err = v.Revoke(ctx, grant.NewGrant(vault, "create items", alice))
+	require.NoError(t, err)
+	require.Nil(t, annos)
+	require.True(t, ran(emu, "vault user revoke --vault V1 --user U1 --permissions create_items"))
+
+	annos, err = v.Revoke(ctx, grant.NewGrant(vault, "create items", alice))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyRevoked{}), annos)
+
+	annos, err = v.Grant(ctx, engineering, ent.NewPermissionEntitlement(vault, "create items"))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyExists{}), annos)
+
+	annos, err = v.Revoke(ctx, grant.NewGrant(vault, "edit items", engineering))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyRevoked{}), annos)
+	require.False(t, ran(emu, "vault group grant"))
+	require.False(t, ran(emu, "vault group revoke"))
+}
+
+func TestVaultGrantOfInheritedPermissionIsIdempotent(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", emu)), nil, false)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	bob, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U2", Name: "Bob Jones"}}, testAccountID)
+	require.NoError(t, err)
+
+	// Bob inherits create items from Engineering, and is granted it directly as well.
+	annos, err := v.Grant(ctx, bob, ent.NewPermissionEntitlement(vault, "create items"))
+	require.NoError(t, err)
+	require.Nil(t, annos)
+
+	annos, err = v.Grant(ctx, bob, ent.NewPermissionEntitlement(vault, "create items"))
+	require.NoError(t, err)
+	require.Equal(t, annotations.New(&v2.GrantAlreadyExists{}), annos)
+
+	grants := slices.DeleteFunc(emu.Calls(), func(c optest.Call) bool {
+		return !strings.HasPrefix(strings.Join(c.Args, " "), "vault user grant ")
+	})
+	require.Len(t, grants, 1)
+
+	require.Equal(t, "direct_and_group Engineering", grantSources(t, vaultGrants(t, v, vault))["vault:V1:create items:user:U2"])
+}
+
+func TestVaultGroupGrantAndRevoke(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator().AddGroup(onepassword.Group{BaseType: onepassword.BaseType{ID: "G2", Name: "Design"}})
+	cli := onepassword.NewCli("service", "", emu)
+	v := vaultBuilder(newAccounts(cli), nil, false)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	design, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G2", Name: "Design"}}, testAccountID)
+	require.NoError(t, err)
+
+	_, err = v.Grant(ctx, design, ent.NewPermissionEntitlement(vault, "create items"))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "vault group grant --vault V1 --group G2 --permissions view_items,create_items"))
+
+	groups, err := cli.ListVaultGroups(ctx, "V1")
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	require.Equal(t, "G2", groups[1].ID)
+	require.Equal(t, []string{"create_items", "view_items"}, groups[1].Permissions)
+
+	_, err = v.Revoke(ctx, grant.NewGrant(vault, "create items", design))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "vault group revoke --vault V1 --group G2"))
+
+	groups, err = cli.ListVaultGroups(ctx, "V1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"view_items"}, groups[1].Permissions)
+}
+
+func TestGrantsUseProvisioningReserve(t *testing.T) {
+	ctx := t.Context()
+	// Sync reads have spent the budget down to the half reserved for provisioning.
+	emu := newTestEmulator().SetRateLimits(onepassword.RateLimit{Type: "account", Action: "read_write", Limit: 100, Remaining: 50, Reset: 3600})
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithRateBudget(0.5))
+	accounts := newAccounts(cli)
+
+	_, err := cli.ListVaults(onepassword.WithBudgetPhase(ctx, "vaults"))
+	require.ErrorIs(t, err, onepassword.ErrRateLimited)
+
+	vault, err := vaultResource(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V1", Name: "Shared"}}, testAccountID)
+	require.NoError(t, err)
+	group, err := groupResource(onepassword.Group{BaseType: onepassword.BaseType{ID: "G1", Name: "Engineering"}}, testAccountID)
+	require.NoError(t, err)
+	alice, err := userResource(onepassword.User{BaseType: onepassword.BaseType{ID: "U1", Name: "Alice Smith"}}, testAccountID)
+	require.NoError(t, err)
+
+	// Grants read the current access before they write, both out of the reserve.
+	_, err = vaultBuilder(accounts, nil, false).Grant(ctx, alice, ent.NewPermissionEntitlement(vault, "edit items"))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "vault user grant --vault V1 --user U1"))
+
+	_, err = groupBuilder(accounts).Grant(ctx, alice, ent.NewAssignmentEntitlement(group, memberEntitlement))
+	require.NoError(t, err)
+	require.True(t, ran(emu, "group user grant --group G1 --role member --user U1"))
+}
+
+func TestVaultCreate(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator()
+	v := vaultBuilder(newAccounts(onepassword.NewCli("service", "", emu)), nil, false)
+
+	settings, err := structpb.NewStruct(map[string]any{"icon": "buildings", "allow_admins_to_manage": false})
+	require.NoError(t, err)
+	created, _, err := v.Create(ctx, &v2.Resource{
+		DisplayName:      "Team Falcon",
+		Description:      "Shared by the Falcon team",
+		ParentResourceId: testAccountID,
+		Annotations:      annotations.New(settings),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Team Falcon", created.DisplayName)
+	require.Equal(t, testAccountID.Resource, created.ParentResourceId.Resource)
+
+	calls := emu.Calls()
+	require.Equal(t, []string{
+		"vault", "create", "Team Falcon", "--allow-admins-to-manage", "false",
+		"--description", "Shared by the Falcon team", "--icon", "buildings", "--format=json",
+	}, calls[len(calls)-1].Args)
+
+	_, _, err = v.Create(ctx, &v2.Resource{DisplayName: "Team Hawk", ParentResourceId: testAccountID})
+	require.NoError(t, err)
+	calls = emu.Calls()
+	require.Equal(t, []string{"vault", "create", "Team Hawk", "--allow-admins-to-manage", "true", "--format=json"}, calls[len(calls)-1].Args)
+
+	_, _, err = v.Create(ctx, &v2.Resource{ParentResourceId: testAccountID})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestVaultDelete(t *testing.T) {
+	ctx := t.Context()
+	newEmulator := func() *optest.Emulator {
+		return newTestEmulator().
+			AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V2", Name: "Empty"}}).
+			AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V3", Name: "Passwords"}, Items: 12})
+	}
+	vaultID := func(id string) *v2.ResourceId {
+		return &v2.ResourceId{ResourceType: resourceTypeVault.Id, Resource: id}
+	}
+
+	emu := newEmulator()
+	cli := onepassword.NewCli("service", "", emu)
+	v := vaultBuilder(newAccounts(cli), nil, false)
+
+	_, err := v.Delete(ctx, vaultID("V2"))
+	require.NoError(t, err)
+	_, err = cli.GetVault(ctx, "V2")
+	require.ErrorIs(t, err, onepassword.ErrNotFound)
+
+	_, err = v.Delete(ctx, vaultID("V3"))
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.ErrorContains(t, err, "holds 12 items")
+	require.False(t, ran(emu, "vault delete V3"))
+
+	cli = onepassword.NewCli("service", "", newEmulator())
+	v = vaultBuilder(newAccounts(cli), nil, true)
+	_, err = v.Delete(ctx, vaultID("V3"))
+	require.NoError(t, err)
+	_, err = cli.GetVault(ctx, "V3")
+	require.ErrorIs(t, err, onepassword.ErrNotFound)
+}
+
+func TestVaultDeleteReadsCurrentItems(t *testing.T) {
+	ctx := t.Context()
+	emu := newTestEmulator().AddVault(onepassword.Vault{BaseType: onepassword.BaseType{ID: "V2", Name: "Empty"}})
+	cli := onepassword.NewCli("service", "", emu, onepassword.WithCache(onepassword.DefaultCacheTTL))
+	v := vaultBuilder(newAccounts(cli), nil, false)
+
+	// A sync caches the vault as empty, and items are then added to it outside of the connector.
+	vault, err := cli.GetVault(ctx, "V2")
+	require.NoError(t, err)
+	require.Zero(t, vault.Items)
+	emu.SetVaultItems("V2", 3)
+
+	_, err = v.Delete(ctx, &v2.ResourceId{ResourceType: resourceTypeVault.Id, Resource: "V2"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.ErrorContains(t, err, "holds 3 items")
+	require.False(t, ran(emu, "vault delete V2"))
+}
@@ -1,5 +0,0 @@
-#!/bin/bash
-
-unset OP_SERVICE_ACCOUNT_TOKEN
-op signout --all 
-op account forget --all
\ No newline at end of file